@@ -2,22 +2,26 @@ package app
 
 import (
 	"database/sql"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 // create a database connection and set pooling
-// dsn format : user:password@tcp(localhost:5555)/dbname?tls=skip-verify&autocommit=true
-func NewDB() *sql.DB {
-	db, err := sql.Open("mysql", "root:@tcp(localhost:3306)/latihan_go_restapi")
-	helper.PanicIfError(err)
+// from the database section of the config.
+// the config drivers are named like the database/sql drivers
+func NewDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
 
-	db.SetMaxOpenConns(20)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(60 * time.Minute)
-	db.SetConnMaxIdleTime(10 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	return db
+	return db, nil
 }
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"database/sql"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/migration"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+)
+
+// create the service behind /healthz and /readyz.
+// db is nil with the memory driver
+func NewHealthService(db *sql.DB, database config.DatabaseConfig, cfg config.HealthConfig) (service.HealthService, error) {
+	if db == nil {
+		return service.NewHealthService(nil, nil, cfg.Timeout), nil
+	}
+
+	migrator, err := migration.NewMigrator(db, database.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewHealthService(db, migrator, cfg.Timeout), nil
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// create the logger of the app, writing json or text into out.
+// lines logged with the context of a request carry its request_id,
+// and its trace_id when it is traced
+func NewLogger(cfg config.LogConfig, out io.Writer) *slog.Logger {
+	var level slog.Level
+	// the config only allows names slog knows
+	level.UnmarshalText([]byte(cfg.Level))
+
+	options := &slog.HandlerOptions{Level: level}
+	if cfg.Format == "json" {
+		return slog.New(requestIdHandler{slog.NewJSONHandler(out, options)})
+	}
+
+	return slog.New(requestIdHandler{slog.NewTextHandler(out, options)})
+}
+
+// add the request id and the trace id of the context to every record
+type requestIdHandler struct {
+	slog.Handler
+}
+
+func (h requestIdHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := helper.RequestIdFrom(ctx); requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsSampled() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIdHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIdHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIdHandler) WithGroup(name string) slog.Handler {
+	return requestIdHandler{h.Handler.WithGroup(name)}
+}
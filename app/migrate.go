@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/migration"
+)
+
+// run the migrate subcommand:
+//
+//	migrate up                  apply every pending migration
+//	migrate down [steps]        roll back the last steps migrations, 1 by default
+//	migrate status              list the migrations and whether they are applied
+//	migrate baseline <version>  record a migration as applied without running it
+func RunMigrateCommand(ctx context.Context, cfg config.DatabaseConfig, args []string, out io.Writer) error {
+	if cfg.Driver == "memory" {
+		return errors.New("the memory driver has no schema to migrate")
+	}
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [steps] | status | baseline <version>")
+	}
+
+	db, err := NewDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migration.NewMigrator(db, cfg.Driver)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		migrations, err := migrator.Up(ctx)
+		printMigrations(out, "applied", migrations)
+		if err == nil && len(migrations) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number, got %s", args[1])
+			}
+		}
+
+		migrations, err := migrator.Down(ctx, steps)
+		printMigrations(out, "rolled back", migrations)
+		if err == nil && len(migrations) == 0 {
+			fmt.Fprintln(out, "nothing to roll back")
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS")
+		for _, status := range statuses {
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, migrationState(status))
+		}
+		return writer.Flush()
+
+	case "baseline":
+		if len(args) < 2 {
+			return errors.New("usage: migrate baseline <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("version must be a number, got %s", args[1])
+		}
+
+		recorded, err := migrator.Baseline(ctx, version)
+		if err != nil {
+			return err
+		}
+		printMigrations(out, "recorded as applied", []migration.Migration{recorded})
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %s, expected up, down, status or baseline", args[0])
+	}
+}
+
+// apply pending migrations when database.migrate_on_start is set.
+// db is nil with the memory driver, there is nothing to migrate then
+func MigrateOnStart(ctx context.Context, db *sql.DB, cfg config.DatabaseConfig) error {
+	if !cfg.MigrateOnStart || db == nil {
+		return nil
+	}
+
+	migrator, err := migration.NewMigrator(db, cfg.Driver)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := migrator.Up(ctx)
+	for _, migration := range migrations {
+		slog.InfoContext(ctx, "applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return err
+}
+
+func printMigrations(out io.Writer, action string, migrations []migration.Migration) {
+	for _, migration := range migrations {
+		fmt.Fprintf(out, "%s %d %s\n", action, migration.Version, migration.Name)
+	}
+}
+
+func migrationState(status migration.Status) string {
+	switch {
+	case status.Unknown:
+		return "applied " + status.AppliedAt.Format(time.RFC3339) + ", unknown to this build"
+	case status.Changed:
+		return "applied " + status.AppliedAt.Format(time.RFC3339) + ", changed since"
+	case status.AppliedAt != nil:
+		return "applied " + status.AppliedAt.Format(time.RFC3339)
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+)
+
+// remove soft deleted users older than the retention
+// every interval, until ctx is done.
+// does nothing when the retention is 0
+func StartPurgeJob(ctx context.Context, userService service.UserService, cfg config.PurgeConfig) {
+	if cfg.Retention == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := userService.Purge(ctx, cfg.Retention)
+				if err != nil {
+					slog.ErrorContext(ctx, "purge deleted users", "error", err)
+				} else if purged > 0 {
+					slog.InfoContext(ctx, "purged deleted users", "count", purged)
+				}
+			}
+		}
+	}()
+}
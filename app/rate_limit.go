@@ -0,0 +1,18 @@
+package app
+
+import (
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+)
+
+// create the rate limiter of the routes from the config
+func NewRateLimiter(cfg config.RateLimitConfig) *middleware.RateLimiter {
+	routes := map[string]middleware.RateLimit{}
+	for route, rule := range cfg.Routes {
+		routes[route] = middleware.RateLimit{Requests: rule.Requests, Period: rule.Period, Burst: rule.Burst}
+	}
+
+	limit := middleware.RateLimit{Requests: cfg.Requests, Period: cfg.Period, Burst: cfg.Burst}
+	failedAuth := middleware.RateLimit{Requests: cfg.FailedAuth.Requests, Period: cfg.FailedAuth.Period, Burst: cfg.FailedAuth.Burst}
+	return middleware.NewRateLimiter(limit, routes, failedAuth, cfg.TrustForwardedFor)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+)
+
+// create the http server with the timeouts of the config
+func NewServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serve on listener until ctx is done, then shut down:
+// /readyz starts failing, requests are still served for cfg.DrainDelay,
+// then new connections are refused and the requests in flight
+// get cfg.ShutdownTimeout to finish
+func RunServer(ctx context.Context, server *http.Server, listener net.Listener, cfg config.ServerConfig, healthService service.HealthService) error {
+	served := make(chan error, 1)
+	go func() {
+		// the certificate comes from server.TLSConfig, see NewTLSConfig
+		if server.TLSConfig != nil {
+			served <- server.ServeTLS(listener, "", "")
+		} else {
+			served <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "drain_delay", cfg.DrainDelay, "timeout", cfg.ShutdownTimeout)
+	healthService.Drain()
+
+	select {
+	case err := <-served:
+		return err
+	case <-time.After(cfg.DrainDelay):
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		// cut the requests still running
+		server.Close()
+		return fmt.Errorf("requests still running after %s: %w", cfg.ShutdownTimeout, err)
+	}
+
+	err = <-served
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"database/sql"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/repository"
+)
+
+// Storage is everything the services need
+// to read and write data, for the configured driver
+type Storage struct {
+	// nil with the memory driver
+	DB               *sql.DB
+	Transactor       repository.Transactor
+	UserRepository   repository.UserRepository
+	APIKeyRepository repository.APIKeyRepository
+}
+
+// create the storage picked by cfg.Driver.
+// the repositories are timed in metrics.RepositoryDuration
+func NewStorage(cfg config.DatabaseConfig) (*Storage, error) {
+	if cfg.Driver == "memory" {
+		db := repository.NewMemoryDB()
+		return &Storage{
+			Transactor:       repository.NewMemoryTransactor(db),
+			UserRepository:   repository.NewUserRepositoryMetrics(repository.NewUserRepositoryMemory(db)),
+			APIKeyRepository: repository.NewAPIKeyRepositoryMetrics(repository.NewAPIKeyRepositoryMemory(db)),
+		}, nil
+	}
+
+	dialect, err := repository.DialectFor(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := NewDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		DB:               db,
+		Transactor:       repository.NewSQLTransactor(db),
+		UserRepository:   repository.NewUserRepositoryMetrics(repository.NewUserRepository(db, dialect)),
+		APIKeyRepository: repository.NewAPIKeyRepositoryMetrics(repository.NewAPIKeyRepository(db, dialect)),
+	}, nil
+}
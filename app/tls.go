@@ -0,0 +1,190 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+)
+
+// how often the files are looked at for a change,
+// at most, they are only looked at when a client connects
+const tlsReloadInterval = time.Second
+
+// create the tls config of the server, nil when tls isn't configured.
+// the certificate and the client CAs are read again when their files change
+func NewTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+
+	files := &tlsFiles{cfg: cfg}
+	err := files.load()
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	if cfg.ClientCAFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	if cfg.RequireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// never called, GetConfigForClient answers first.
+		// it tells http.Server a certificate is configured
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			certificate, _ := files.current()
+			return &certificate, nil
+		},
+		// every handshake gets the files as they are now
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, clientCAs := files.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{certificate},
+				ClientAuth:   clientAuth,
+				ClientCAs:    clientCAs,
+				NextProtos:   []string{"h2", "http/1.1"},
+			}, nil
+		},
+	}, nil
+}
+
+// the files of TLSConfig and what was read from them
+type tlsFiles struct {
+	cfg config.TLSConfig
+
+	mu          sync.Mutex
+	certificate tls.Certificate
+	clientCAs   *x509.CertPool
+	// modification times of the files when they were read
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+// the certificate and the client CAs, read again
+// when a file changed since the last time
+func (f *tlsFiles) current() (tls.Certificate, *x509.CertPool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.lastCheck) >= tlsReloadInterval {
+		f.lastCheck = time.Now()
+
+		modTimes, err := f.statFiles()
+		if err == nil && !equalTimes(modTimes, f.modTimes) {
+			// the old files keep being used until the new ones are valid,
+			// e.g. while only the cert is written and not the key yet
+			err = f.read()
+			if err == nil {
+				slog.Info("reloaded tls certificate", "cert_file", f.cfg.CertFile)
+			}
+		}
+		if err != nil {
+			slog.Warn("reload tls certificate", "error", err)
+		}
+	}
+
+	return f.certificate, f.clientCAs
+}
+
+func (f *tlsFiles) load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastCheck = time.Now()
+	return f.read()
+}
+
+// read the files, must be called with mu held
+func (f *tlsFiles) read() error {
+	modTimes, err := f.statFiles()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if f.cfg.ClientCAFile != "" {
+		bundle, err := os.ReadFile(f.cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("no certificate found in %s", f.cfg.ClientCAFile)
+		}
+	}
+
+	f.certificate = certificate
+	f.clientCAs = clientCAs
+	f.modTimes = modTimes
+	return nil
+}
+
+func (f *tlsFiles) statFiles() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, path := range []string{f.cfg.CertFile, f.cfg.KeyFile, f.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	return modTimes, nil
+}
+
+func equalTimes(a []time.Time, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// create the plain http server on cfg.TLS.RedirectAddr sending
+// every request to the same url on https, on the port of cfg.Addr
+func NewRedirectServer(cfg config.ServerConfig) *http.Server {
+	_, httpsPort, _ := net.SplitHostPort(cfg.Addr)
+
+	redirect := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host, _, err := net.SplitHostPort(request.Host)
+		if err != nil {
+			// no port in the Host header
+			host = request.Host
+		}
+
+		url := "https://" + net.JoinHostPort(host, httpsPort) + request.URL.RequestURI()
+		// 308 keeps the method and the body, unlike 301
+		http.Redirect(writer, request, url, http.StatusPermanentRedirect)
+	})
+
+	server := NewServer(cfg, redirect)
+	server.Addr = cfg.TLS.RedirectAddr
+	return server
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+)
+
+// create the service verifying bearer tokens
+// with the keys of the jwt config.
+// nil when no key is configured, bearer tokens are refused then
+func NewTokenService(cfg config.JWTConfig) (service.TokenService, error) {
+	var keys []service.TokenKey
+
+	if cfg.Secret != "" {
+		keys = append(keys, service.TokenKey{Algorithm: "HS256", Key: []byte(cfg.Secret)})
+	}
+
+	if cfg.PublicKeyFile != "" {
+		pemKeys, err := readPEMKeys(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pemKeys...)
+	}
+
+	if cfg.JWKSFile != "" {
+		jwksKeys, err := readJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwksKeys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	return service.NewTokenService(keys, cfg.Issuer, cfg.Audience, cfg.Leeway), nil
+}
+
+// read every public key of a pem file
+func readPEMKeys(path string) ([]service.TokenKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []service.TokenKey
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		key, err := tokenKey("", publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no public key found", path)
+	}
+	return keys, nil
+}
+
+// a key of a JWKS file, only the members we use
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	// oct
+	K string `json:"k"`
+}
+
+// read the signing keys of a JWKS file
+func readJWKS(path string) ([]service.TokenKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.Unmarshal(content, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var keys []service.TokenKey
+	for _, jwk := range jwks.Keys {
+		// keys meant for encryption don't verify tokens
+		if jwk.Use == "enc" {
+			continue
+		}
+
+		key, err := jwkTokenKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", path, jwk.Kid, err)
+		}
+		if jwk.Alg != "" && jwk.Alg != key.Algorithm {
+			return nil, fmt.Errorf("%s: key %q: algorithm %s is not supported", path, jwk.Kid, jwk.Alg)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no signing key found", path)
+	}
+	return keys, nil
+}
+
+func jwkTokenKey(jwk jsonWebKey) (service.TokenKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return service.TokenKey{}, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return service.TokenKey{}, err
+		}
+
+		return tokenKey(jwk.Kid, &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		})
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return service.TokenKey{}, fmt.Errorf("curve %s is not supported", jwk.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return service.TokenKey{}, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return service.TokenKey{}, errors.New("invalid Ed25519 key")
+		}
+
+		return tokenKey(jwk.Kid, ed25519.PublicKey(x))
+
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return service.TokenKey{}, err
+		}
+
+		return service.TokenKey{Id: jwk.Kid, Algorithm: "HS256", Key: k}, nil
+
+	default:
+		return service.TokenKey{}, fmt.Errorf("key type %s is not supported", jwk.Kty)
+	}
+}
+
+// the algorithm of a public key is given by its type
+func tokenKey(kid string, publicKey interface{}) (service.TokenKey, error) {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return service.TokenKey{Id: kid, Algorithm: "RS256", Key: publicKey}, nil
+	case ed25519.PublicKey:
+		return service.TokenKey{Id: kid, Algorithm: "EdDSA", Key: publicKey}, nil
+	default:
+		return service.TokenKey{}, fmt.Errorf("public key %T is not supported", publicKey)
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+)
+
+// create the validator of the services.
+// fields are named after their json tag so validation errors
+// point at the request body, with messages in every language
+func NewValidator() (*validator.Validate, error) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(jsonName)
+
+	err := exception.RegisterTranslations(validate)
+	if err != nil {
+		return nil, err
+	}
+
+	return validate, nil
+}
+
+// the name of field in json, its go name when it has no json tag
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
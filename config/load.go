@@ -0,0 +1,156 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// build the config from the defaults, the file at path and the environment.
+// path may be empty, in that case APP_CONFIG_FILE is used if set.
+// the result is validated, so a misconfigured app fails on start.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("APP_CONFIG_FILE")
+	}
+
+	if path != "" {
+		err := loadFile(path, &cfg)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	err := loadEnv(reflect.ValueOf(&cfg).Elem())
+	if err != nil {
+		return cfg, err
+	}
+
+	err = validator.New().Struct(cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if cfg.Auth.CursorSecret == "" {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Auth.CursorSecret = hex.EncodeToString(secret)
+	}
+
+	return cfg, nil
+}
+
+// read a yaml or toml file, picked by its extension
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cfg)
+	case ".toml":
+		err = toml.Unmarshal(content, cfg)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// walk through the config struct
+// and set every field whose env variable is set
+func loadEnv(value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		structField := value.Type().Field(i)
+
+		if field.Kind() == reflect.Struct {
+			err := loadEnv(field)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := structField.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		err := setField(field, env)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// convert the text of an env variable into the field type
+func setField(field reflect.Value, text string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(text)
+	case int:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(number))
+	case float64:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(number)
+	case bool:
+		boolean, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolean)
+	case time.Duration:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// write the config as yaml with the secrets hidden.
+// used by --print-config to check the effective settings
+func Print(writer io.Writer, cfg Config) error {
+	redact(reflect.ValueOf(&cfg).Elem())
+
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+
+	return encoder.Encode(cfg)
+}
+
+// replace every non empty secret field with "[REDACTED]"
+func redact(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+
+		if value.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString("[REDACTED]")
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/julienschmidt/httprouter"
+)
+
+type APIKeyControllerImpl struct {
+	APIKeyService service.APIKeyService
+}
+
+// create a constructor
+// that will be called in main.go
+func NewAPIKeyController(APIKeyService service.APIKeyService) APIKeyController {
+	return &APIKeyControllerImpl{
+		APIKeyService: APIKeyService,
+	}
+}
+
+func (c *APIKeyControllerImpl) Issue(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	payload := web.APIKeyCreatePayload{}
+
+	err := decodeJSON(request, &payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	serviceResponse, err := c.APIKeyService.Issue(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	// the key can't be read again,
+	// make sure no cache keeps a copy either
+	writer.Header().Set("Cache-Control", "no-store")
+
+	response := web.HttpResponse{
+		Code:   200,
+		Status: "OK",
+		Data:   serviceResponse,
+	}
+
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
+}
+
+func (c *APIKeyControllerImpl) Revoke(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	keyId, err := strconv.Atoi(params.ByName("keyId"))
+	if err != nil {
+		exception.ErrorHandler(writer, request, exception.NewValidationError("keyId must be a number"))
+		return
+	}
+
+	serviceResponse, err := c.APIKeyService.Revoke(request.Context(), keyId)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	response := web.HttpResponse{
+		Code:   200,
+		Status: "OK",
+		Data:   serviceResponse,
+	}
+
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
+}
+
+func (c *APIKeyControllerImpl) FindAll(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	serviceResponse, err := c.APIKeyService.FindAll(request.Context())
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	response := web.HttpResponse{
+		Code:   200,
+		Status: "OK",
+		Data:   serviceResponse,
+	}
+
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
+}
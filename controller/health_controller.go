@@ -0,0 +1,12 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type HealthController interface {
+	Live(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+	Ready(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/julienschmidt/httprouter"
+)
+
+type HealthControllerImpl struct {
+	HealthService service.HealthService
+}
+
+// create a constructor
+// that will be called in main.go
+func NewHealthController(HealthService service.HealthService) HealthController {
+	return &HealthControllerImpl{
+		HealthService: HealthService,
+	}
+}
+
+func (c *HealthControllerImpl) Live(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	writeHealth(writer, c.HealthService.Live(request.Context()))
+}
+
+func (c *HealthControllerImpl) Ready(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	writeHealth(writer, c.HealthService.Ready(request.Context()))
+}
+
+// 503 when a check failed, that's what probes look at
+func writeHealth(writer http.ResponseWriter, health web.HealthResponse) {
+	code := http.StatusOK
+	if health.Status != web.HealthOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	// a probe must never see an old answer
+	writer.Header().Set("Cache-Control", "no-store")
+
+	response := web.HttpResponse{
+		Code:   code,
+		Status: http.StatusText(code),
+		Data:   health,
+	}
+
+	helper.WriteToResponseBody(writer, code, response)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+)
+
+// decode the json body of request into payload.
+// anything but exactly one json value matching payload is refused,
+// so a typo in a field name doesn't silently leave it empty
+func decodeJSON(request *http.Request, payload interface{}) error {
+	contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if contentType != "application/json" {
+		return exception.NewUnsupportedMediaTypeError("request body must be application/json")
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(payload)
+	if err != nil {
+		return decodeError(err)
+	}
+
+	// a second value, or garbage after the first one
+	err = decoder.Decode(&struct{}{})
+	if err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return exception.NewValidationError("request body must be a single json value")
+	}
+
+	return nil
+}
+
+// turn the error of json.Decoder into one the client understands
+func decodeError(err error) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return exception.NewPayloadTooLargeError("request body must not be larger than " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes")
+	case errors.Is(err, io.EOF):
+		return exception.NewValidationError("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return exception.NewValidationError("request body is malformed json: unexpected end")
+	case errors.As(err, &syntaxError):
+		return exception.NewValidationError("request body is malformed json at offset " + strconv.FormatInt(syntaxError.Offset, 10))
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			return exception.NewValidationError("request body must be " + jsonType(typeError.Type))
+		}
+		return exception.NewValidationError(jsonField(typeError.Field) + " must be " + jsonType(typeError.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// DisallowUnknownFields has no error type of its own
+		return exception.NewValidationError("unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return exception.NewValidationError("request body is malformed json")
+	}
+}
+
+// the field of an UnmarshalTypeError as a json path, the indexes
+// of arrays in brackets. "0.name" is "$[0].name", "name" stays "name"
+func jsonField(field string) string {
+	path := "$"
+	for _, name := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(name); err == nil {
+			path += "[" + name + "]"
+		} else {
+			path += "." + name
+		}
+	}
+
+	return strings.TrimPrefix(path, "$.")
+}
+
+// what a go type is called in json
+func jsonType(goType reflect.Type) string {
+	switch goType.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// the error of reading the body as it is, e.g. for a patch
+func readError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return decodeError(err)
+	}
+
+	return err
+}
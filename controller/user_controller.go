@@ -9,7 +9,12 @@ import (
 type UserController interface {
 	Create(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
 	Update(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+	Patch(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
 	Delete(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+	Restore(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
 	FindById(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
 	FindAll(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+	BulkCreate(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+	BulkUpdate(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
+	BulkDelete(writer http.ResponseWriter, request *http.Request, params httprouter.Params)
 }
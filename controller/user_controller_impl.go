@@ -1,11 +1,15 @@
 package controller
 
 import (
-	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
 	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
 	"github.com/iqbaltaufiq/latihan-restapi/model/web"
 	"github.com/iqbaltaufiq/latihan-restapi/service"
 	"github.com/julienschmidt/httprouter"
@@ -27,11 +31,18 @@ func (c *UserControllerImpl) Create(writer http.ResponseWriter, request *http.Re
 	payload := web.UserCreatePayload{}
 
 	// decode request
-	decoder := json.NewDecoder(request.Body)
-	decoder.Decode(&payload)
+	err := decodeJSON(request, &payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	// send it to service
-	serviceResponse := c.UserService.Create(request.Context(), payload)
+	serviceResponse, err := c.UserService.Create(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	// send the returned value into encoder
 	response := web.HttpResponse{
@@ -40,10 +51,8 @@ func (c *UserControllerImpl) Create(writer http.ResponseWriter, request *http.Re
 		Data:   serviceResponse,
 	}
 
-	writer.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(writer)
-	err := encoder.Encode(response)
-	helper.PanicIfError(err)
+	writer.Header().Set("ETag", helper.ETag(serviceResponse.Version))
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
 }
 
 func (c *UserControllerImpl) Update(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
@@ -51,17 +60,35 @@ func (c *UserControllerImpl) Update(writer http.ResponseWriter, request *http.Re
 	payload := web.UserUpdatePayload{}
 
 	// decode the request stream
-	decoder := json.NewDecoder(request.Body)
-	decoder.Decode(&payload)
+	err := decodeJSON(request, &payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	// userId in param is a string
 	// convert it to int first
-	userId, err := strconv.Atoi(params.ByName("userId"))
-	helper.PanicIfError(err)
+	userId, err := userIdParam(params)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	payload.Id = userId
 
-	serviceResponse := c.UserService.Update(request.Context(), payload)
+	// only update if the user is still in the version the client read
+	versions, err := ifMatchVersions(request)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+	payload.IfMatch = versions
+
+	serviceResponse, err := c.UserService.Update(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	response := web.HttpResponse{
 		Code:   200,
@@ -69,16 +96,72 @@ func (c *UserControllerImpl) Update(writer http.ResponseWriter, request *http.Re
 		Data:   serviceResponse,
 	}
 
-	writer.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(writer)
-	encoder.Encode(response)
+	writer.Header().Set("ETag", helper.ETag(serviceResponse.Version))
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
+}
+
+func (c *UserControllerImpl) Patch(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	userId, err := userIdParam(params)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	// the patch format is told by the content type,
+	// the service rejects anything else
+	patchType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+
+	patch, err := io.ReadAll(request.Body)
+	if err != nil {
+		exception.ErrorHandler(writer, request, readError(err))
+		return
+	}
+
+	versions, err := ifMatchVersions(request)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	serviceResponse, err := c.UserService.Patch(request.Context(), web.UserPatchPayload{
+		Id:       userId,
+		Type:     patchType,
+		Patch:    patch,
+		Versions: versions,
+	})
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	response := web.HttpResponse{
+		Code:   200,
+		Status: "OK",
+		Data:   serviceResponse,
+	}
+
+	writer.Header().Set("ETag", helper.ETag(serviceResponse.Version))
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
 }
 
 func (c *UserControllerImpl) Delete(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	userId, err := strconv.Atoi(params.ByName("userId"))
-	helper.PanicIfError(err)
+	userId, err := userIdParam(params)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	versions, err := ifMatchVersions(request)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
-	c.UserService.Delete(request.Context(), userId)
+	err = c.UserService.Delete(request.Context(), userId, versions)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	response := web.HttpResponse{
 		Code:   200,
@@ -86,40 +169,287 @@ func (c *UserControllerImpl) Delete(writer http.ResponseWriter, request *http.Re
 		Data:   "Deleted successfully",
 	}
 
-	writer.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(writer)
-	err = encoder.Encode(response)
-	helper.PanicIfError(err)
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
+}
+
+func (c *UserControllerImpl) Restore(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	userId, err := userIdParam(params)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	versions, err := ifMatchVersions(request)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	user, err := c.UserService.Restore(request.Context(), userId, versions)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	response := web.HttpResponse{
+		Code:   200,
+		Status: "OK",
+		Data:   user,
+	}
+
+	writer.Header().Set("ETag", helper.ETag(user.Version))
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
 }
 
 func (c *UserControllerImpl) FindById(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	userId, err := strconv.Atoi(params.ByName("userId"))
-	helper.PanicIfError(err)
+	userId, err := userIdParam(params)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	// ?include_deleted=true finds a soft deleted user too
+	includeDeleted, err := queryBool("include_deleted", request.URL.Query().Get("include_deleted"))
+	if err == nil {
+		err = checkIncludeDeleted(request, includeDeleted)
+	}
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	user, err := c.UserService.FindById(request.Context(), userId, includeDeleted)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	// the client already has this version,
+	// no need to send it again
+	etag := helper.ETag(user.Version)
+	writer.Header().Set("ETag", etag)
+	if helper.ETagMatches(request.Header.Get("If-None-Match"), etag) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	user := c.UserService.FindById(request.Context(), userId)
 	response := web.HttpResponse{
 		Code:   200,
 		Status: "OK",
 		Data:   user,
 	}
 
-	writer.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(writer)
-	err = encoder.Encode(response)
-	helper.PanicIfError(err)
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
 }
 
 func (c *UserControllerImpl) FindAll(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	users := c.UserService.FindAll(request.Context())
+	// parse the query string
+	// e.g. ?page=2&size=10&sort=name,-id&occupation=student
+	// or ?after=<next_cursor>&limit=10
+	// every other key is treated as a filter
+	payload := web.UserFindAllPayload{Filters: map[string]string{}}
+	for key, values := range request.URL.Query() {
+		value := values[0]
+
+		var err error
+		switch key {
+		case "page":
+			payload.Page, err = queryInt(key, value)
+		case "size":
+			payload.Size, err = queryInt(key, value)
+		case "after":
+			payload.After = value
+		case "limit":
+			payload.Limit, err = queryInt(key, value)
+		case "include_deleted":
+			payload.IncludeDeleted, err = queryBool(key, value)
+		case "sort":
+			for _, field := range strings.Split(value, ",") {
+				payload.Sort = append(payload.Sort, strings.TrimSpace(field))
+			}
+		default:
+			payload.Filters[key] = value
+		}
+
+		if err != nil {
+			exception.ErrorHandler(writer, request, err)
+			return
+		}
+	}
+
+	err := checkIncludeDeleted(request, payload.IncludeDeleted)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	users, err := c.UserService.FindAll(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
 
 	response := web.HttpResponse{
 		Code:   200,
 		Status: "OK",
-		Data:   users,
+		Data:   users.Users,
+		Meta:   users.Meta,
+	}
+
+	helper.WriteToResponseBody(writer, http.StatusOK, response)
+}
+
+func (c *UserControllerImpl) BulkCreate(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	// ?mode=best_effort saves the valid users even if some are not
+	payload := web.UserBulkCreatePayload{Mode: request.URL.Query().Get("mode")}
+
+	err := decodeJSON(request, &payload.Users)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	responses, err := c.UserService.BulkCreate(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	writeBulkResponse(writer, request, payload.Mode, responses)
+}
+
+func (c *UserControllerImpl) BulkUpdate(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	payload := web.UserBulkUpdatePayload{Mode: request.URL.Query().Get("mode")}
+
+	err := decodeJSON(request, &payload.Users)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	responses, err := c.UserService.BulkUpdate(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	writeBulkResponse(writer, request, payload.Mode, responses)
+}
+
+func (c *UserControllerImpl) BulkDelete(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	payload := web.UserBulkDeletePayload{Mode: request.URL.Query().Get("mode")}
+
+	err := decodeJSON(request, &payload.Ids)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	responses, err := c.UserService.BulkDelete(request.Context(), payload)
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	writeBulkResponse(writer, request, payload.Mode, responses)
+}
+
+// write the outcome of every item of a bulk request.
+// 200 : every item succeeded
+// 207 : some items failed in best effort mode, the rest were saved
+// 422 : some items failed in atomic mode, nothing was saved.
+// the items that didn't fail get 424 Failed Dependency
+func writeBulkResponse(writer http.ResponseWriter, request *http.Request, mode string, responses []web.BulkItemResponse) {
+	failed := 0
+	for i := range responses {
+		if responses[i].Err != nil {
+			failed++
+			responses[i].Status = exception.StatusCode(responses[i].Err)
+			responses[i].Error = exception.Message(responses[i].Err)
+			responses[i].Errors = exception.FieldErrors(request, responses[i].Err)
+		} else {
+			responses[i].Status = http.StatusOK
+		}
+	}
+
+	code := http.StatusOK
+	if failed > 0 && mode == web.BulkBestEffort {
+		code = http.StatusMultiStatus
+	} else if failed > 0 {
+		code = http.StatusUnprocessableEntity
+		for i := range responses {
+			if responses[i].Err == nil {
+				responses[i].Status = http.StatusFailedDependency
+			}
+		}
+	}
+
+	response := web.HttpResponse{
+		Code:   code,
+		Status: http.StatusText(code),
+		Data:   responses,
+	}
+
+	helper.WriteToResponseBody(writer, code, response)
+}
+
+// soft deleted users are only shown to admins
+func checkIncludeDeleted(request *http.Request, includeDeleted bool) error {
+	principal, _ := helper.PrincipalFrom(request.Context())
+	if includeDeleted && !principal.HasScope(domain.ScopeAdmin) {
+		return exception.NewForbiddenError("missing scope: " + domain.ScopeAdmin)
+	}
+
+	return nil
+}
+
+// userId in param is a string,
+// convert it to int
+func userIdParam(params httprouter.Params) (int, error) {
+	userId, err := strconv.Atoi(params.ByName("userId"))
+	if err != nil {
+		return 0, exception.NewValidationError("userId must be a number")
+	}
+
+	return userId, nil
+}
+
+// versions the client expects from If-Match, nil if any version will do
+func ifMatchVersions(request *http.Request) ([]int, error) {
+	versions, ok := helper.IfMatchVersions(request.Header.Get("If-Match"))
+	if !ok {
+		return nil, exception.NewPreconditionFailedError("If-Match doesn't match the user")
+	}
+
+	return versions, nil
+}
+
+// convert a query string value into int.
+// an empty value is treated as not set
+func queryInt(key string, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, exception.NewValidationError(key + " must be a number")
+	}
+
+	return number, nil
+}
+
+// convert a query string value into bool.
+// an empty value is treated as false
+func queryBool(key string, value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	boolean, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, exception.NewValidationError(key + " must be true or false")
 	}
 
-	writer.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(writer)
-	err := encoder.Encode(response)
-	helper.PanicIfError(err)
+	return boolean, nil
 }
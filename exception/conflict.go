@@ -0,0 +1,24 @@
+package exception
+
+import "errors"
+
+// ErrConflict matches every ConflictError with errors.Is
+var ErrConflict = errors.New("conflict")
+
+// Handle error when the item clashes with the one in database,
+// e.g. a duplicate unique key
+type ConflictError struct {
+	Message string
+}
+
+func NewConflictError(message string) *ConflictError {
+	return &ConflictError{Message: message}
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
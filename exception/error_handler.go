@@ -1,85 +1,156 @@
 package exception
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/metrics"
 	"github.com/iqbaltaufiq/latihan-restapi/model/web"
 )
 
-// Handler that will process panic.
-// This will be called in router.PanicHandler.
-func PanicHandler(writer http.ResponseWriter, request *http.Request, err interface{}) {
-	if validationError(writer, request, err) {
-		return
+// Write an error returned by the service as response.
+// The status code is picked by the kind of error,
+// anything unknown is treated as an internal server error.
+// Internal errors are logged, their cause isn't sent to the client.
+func ErrorHandler(writer http.ResponseWriter, request *http.Request, err error) {
+	code := StatusCode(err)
+	if code == http.StatusInternalServerError {
+		slog.ErrorContext(request.Context(), "internal error", "method", request.Method, "path", request.URL.Path, "error", err)
 	}
 
-	if notFoundError(writer, request, err) {
-		return
-	}
-
-	internalServerError(writer, request, err)
+	writeError(writer, request, err)
 }
 
-func validationError(writer http.ResponseWriter, request *http.Request, err interface{}) bool {
-	exception, ok := err.(validator.ValidationErrors)
-
-	if !ok {
-		return false
+// the error as problem+json or in the HttpResponse envelope,
+// depending on the Accept header
+func writeError(writer http.ResponseWriter, request *http.Request, err error) {
+	code := StatusCode(err)
+	writer.Header().Add("Vary", "Accept")
+
+	if wantsProblem(request) {
+		problem := web.ProblemDetails{
+			Type:      ProblemType(err),
+			Title:     http.StatusText(code),
+			Status:    code,
+			Detail:    Message(err),
+			Instance:  request.URL.Path,
+			RequestId: helper.RequestIdFrom(request.Context()),
+			Errors:    FieldErrors(request, err),
+		}
+
+		helper.WriteToResponseBodyAs(writer, code, web.ProblemJSON, problem)
+		return
 	}
 
-	writer.Header().Add("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusBadRequest)
-
 	response := web.HttpResponse{
-		Code:   http.StatusBadRequest,
-		Status: "Bad Request",
-		Data:   exception.Error(),
+		Code:      code,
+		Status:    http.StatusText(code),
+		Data:      Message(err),
+		RequestId: helper.RequestIdFrom(request.Context()),
+		Errors:    FieldErrors(request, err),
 	}
 
-	encoder := json.NewEncoder(writer)
-	encodeErr := encoder.Encode(response)
-	helper.PanicIfError(encodeErr)
-
-	return true
+	helper.WriteToResponseBody(writer, code, response)
 }
 
-func notFoundError(writer http.ResponseWriter, request *http.Request, err interface{}) bool {
-	exception, ok := err.(NotFoundError)
+// existing clients keep getting the envelope, problem+json
+// is only sent when it is preferred over application/json
+func wantsProblem(request *http.Request) bool {
+	accept := request.Header.Get("Accept")
+	problem := helper.AcceptQuality(accept, web.ProblemJSON)
+	return problem > 0 && problem > helper.AcceptQuality(accept, "application/json")
+}
 
-	if !ok {
-		return false
+// the type member of the problem+json of an error.
+// internal errors have nothing more to tell than their status
+func ProblemType(err error) string {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return problemTypePrefix + "validation"
+	case errors.Is(err, ErrUnauthorized):
+		return problemTypePrefix + "unauthorized"
+	case errors.Is(err, ErrForbidden):
+		return problemTypePrefix + "forbidden"
+	case errors.Is(err, ErrNotFound):
+		return problemTypePrefix + "not-found"
+	case errors.Is(err, ErrMethodNotAllowed):
+		return problemTypePrefix + "method-not-allowed"
+	case errors.Is(err, ErrConflict):
+		return problemTypePrefix + "conflict"
+	case errors.Is(err, ErrPreconditionFailed):
+		return problemTypePrefix + "precondition-failed"
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return problemTypePrefix + "unsupported-media-type"
+	case errors.Is(err, ErrPayloadTooLarge):
+		return problemTypePrefix + "payload-too-large"
+	case errors.Is(err, ErrTooManyRequests):
+		return problemTypePrefix + "too-many-requests"
+	default:
+		return "about:blank"
 	}
+}
 
-	writer.Header().Add("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusNotFound)
-
-	response := web.HttpResponse{
-		Code:   http.StatusNotFound,
-		Status: "Not Found",
-		Data:   exception.Error,
+// problem types are urns, there is no page to describe them
+const problemTypePrefix = "urn:latihan-restapi:problem:"
+
+// map an error into http status code
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
 	}
+}
 
-	encoder := json.NewEncoder(writer)
-	encodeErr := encoder.Encode(response)
-	helper.PanicIfError(encodeErr)
+// the text of an error that is safe to send to the client.
+// the cause of an internal error may leak details
+// about the database, so only the status is sent
+func Message(err error) string {
+	code := StatusCode(err)
+	if code == http.StatusInternalServerError {
+		return http.StatusText(code)
+	}
 
-	return true
+	return err.Error()
 }
 
-func internalServerError(writer http.ResponseWriter, request *http.Request, err interface{}) {
-	writer.Header().Add("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusInternalServerError)
-
-	response := web.HttpResponse{
-		Code:   http.StatusInternalServerError,
-		Status: "Internal Server Error",
-		Data:   err,
+// Handler that will process panic.
+// This will be called in router.PanicHandler.
+// Errors are returned, not panicked, so this is only a safety net
+// for bugs that slipped through.
+func PanicHandler(writer http.ResponseWriter, request *http.Request, recovered interface{}) {
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("%v", recovered)
 	}
 
-	encoder := json.NewEncoder(writer)
-	encodeErr := encoder.Encode(response)
-	helper.PanicIfError(encodeErr)
+	metrics.Panics.Inc()
+
+	// still in the deferred recover, so the stack is the one that panicked
+	slog.ErrorContext(request.Context(), "panic", "method", request.Method, "path", request.URL.Path, "error", err, "stack", string(debug.Stack()))
+
+	writeError(writer, request, NewInternalError(err))
 }
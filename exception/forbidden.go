@@ -0,0 +1,24 @@
+package exception
+
+import "errors"
+
+// ErrForbidden matches every ForbiddenError with errors.Is
+var ErrForbidden = errors.New("forbidden")
+
+// Handle error when the caller is known
+// but isn't allowed to do what it asked for
+type ForbiddenError struct {
+	Message string
+}
+
+func NewForbiddenError(message string) *ForbiddenError {
+	return &ForbiddenError{Message: message}
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
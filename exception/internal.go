@@ -0,0 +1,28 @@
+package exception
+
+import "errors"
+
+// ErrInternal matches every InternalError with errors.Is
+var ErrInternal = errors.New("internal server error")
+
+// Handle error the client can do nothing about.
+// The cause is kept for errors.As but never sent to the client.
+type InternalError struct {
+	Err error
+}
+
+func NewInternalError(err error) *InternalError {
+	return &InternalError{Err: err}
+}
+
+func (e *InternalError) Error() string {
+	return "internal server error: " + e.Err.Error()
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+func (e *InternalError) Is(target error) bool {
+	return target == ErrInternal
+}
@@ -0,0 +1,23 @@
+package exception
+
+import "errors"
+
+// ErrMethodNotAllowed matches every MethodNotAllowedError with errors.Is
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// Handle error when a route exists but not with the method of the request
+type MethodNotAllowedError struct {
+	Message string
+}
+
+func NewMethodNotAllowedError(message string) *MethodNotAllowedError {
+	return &MethodNotAllowedError{Message: message}
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return e.Message
+}
+
+func (e *MethodNotAllowedError) Is(target error) bool {
+	return target == ErrMethodNotAllowed
+}
@@ -1,14 +1,23 @@
 package exception
 
-// Go doesn't have built-in http error handling
-// make your own error handler
-// by implementing Error interface from Go
+import "errors"
+
+// ErrNotFound matches every NotFoundError with errors.Is
+var ErrNotFound = errors.New("not found")
 
 // Handle error when no item is returned by database
 type NotFoundError struct {
-	Error string
+	Message string
+}
+
+func NewNotFoundError(message string) *NotFoundError {
+	return &NotFoundError{Message: message}
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
 }
 
-func NewNotFoundError(err string) NotFoundError {
-	return NotFoundError{Error: err}
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
 }
@@ -0,0 +1,23 @@
+package exception
+
+import "errors"
+
+// ErrPayloadTooLarge matches every PayloadTooLargeError with errors.Is
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// Handle error when the request body is over server.max_body_bytes
+type PayloadTooLargeError struct {
+	Message string
+}
+
+func NewPayloadTooLargeError(message string) *PayloadTooLargeError {
+	return &PayloadTooLargeError{Message: message}
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return e.Message
+}
+
+func (e *PayloadTooLargeError) Is(target error) bool {
+	return target == ErrPayloadTooLarge
+}
@@ -0,0 +1,24 @@
+package exception
+
+import "errors"
+
+// ErrPreconditionFailed matches every PreconditionFailedError with errors.Is
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Handle error when the item was changed since the client last read it,
+// i.e. If-Match doesn't match the current version
+type PreconditionFailedError struct {
+	Message string
+}
+
+func NewPreconditionFailedError(message string) *PreconditionFailedError {
+	return &PreconditionFailedError{Message: message}
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return e.Message
+}
+
+func (e *PreconditionFailedError) Is(target error) bool {
+	return target == ErrPreconditionFailed
+}
@@ -0,0 +1,23 @@
+package exception
+
+import "errors"
+
+// ErrTooManyRequests matches every TooManyRequestsError with errors.Is
+var ErrTooManyRequests = errors.New("too many requests")
+
+// Handle error when a client used up its rate limit
+type TooManyRequestsError struct {
+	Message string
+}
+
+func NewTooManyRequestsError(message string) *TooManyRequestsError {
+	return &TooManyRequestsError{Message: message}
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return e.Message
+}
+
+func (e *TooManyRequestsError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
@@ -0,0 +1,70 @@
+package exception
+
+import (
+	"net/http"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	id_translations "github.com/go-playground/validator/v10/translations/id"
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+)
+
+// languages of the validation messages,
+// english for the ones not in here
+var translator = ut.New(en.New(), en.New(), id.New())
+
+// the translators validators register their messages in, by locale
+var translators = map[string]sharedTranslator{
+	"en": newSharedTranslator("en"),
+	"id": newSharedTranslator("id"),
+}
+
+// add the messages of every language to validate.
+// the messages are registered per validator,
+// so it has to be the one the services use
+func RegisterTranslations(validate *validator.Validate) error {
+	err := en_translations.RegisterDefaultTranslations(validate, translators["en"])
+	if err != nil {
+		return err
+	}
+
+	return id_translations.RegisterDefaultTranslations(validate, translators["id"])
+}
+
+// the translator of the language the client prefers
+func translatorFor(request *http.Request) ut.Translator {
+	trans, _ := translator.FindTranslator(helper.AcceptLanguages(request.Header.Get("Accept-Language"))...)
+	return translators[trans.Locale()]
+}
+
+// a translator more than one validator can register its messages in.
+// the messages are the same every time, so adding them again
+// replaces them instead of failing as a conflict
+type sharedTranslator struct {
+	ut.Translator
+}
+
+func newSharedTranslator(locale string) sharedTranslator {
+	trans, _ := translator.GetTranslator(locale)
+	return sharedTranslator{Translator: trans}
+}
+
+func (t sharedTranslator) Add(key interface{}, text string, override bool) error {
+	return t.Translator.Add(key, text, true)
+}
+
+func (t sharedTranslator) AddCardinal(key interface{}, text string, rule locales.PluralRule, override bool) error {
+	return t.Translator.AddCardinal(key, text, rule, true)
+}
+
+func (t sharedTranslator) AddOrdinal(key interface{}, text string, rule locales.PluralRule, override bool) error {
+	return t.Translator.AddOrdinal(key, text, rule, true)
+}
+
+func (t sharedTranslator) AddRange(key interface{}, text string, rule locales.PluralRule, override bool) error {
+	return t.Translator.AddRange(key, text, rule, true)
+}
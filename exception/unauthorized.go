@@ -0,0 +1,23 @@
+package exception
+
+import "errors"
+
+// ErrUnauthorized matches every UnauthorizedError with errors.Is
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Handle error when the caller can't be authenticated
+type UnauthorizedError struct {
+	Message string
+}
+
+func NewUnauthorizedError(message string) *UnauthorizedError {
+	return &UnauthorizedError{Message: message}
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Message
+}
+
+func (e *UnauthorizedError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
@@ -0,0 +1,23 @@
+package exception
+
+import "errors"
+
+// ErrUnsupportedMediaType matches every UnsupportedMediaTypeError with errors.Is
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Handle error when the request body is in a format we can't read
+type UnsupportedMediaTypeError struct {
+	Message string
+}
+
+func NewUnsupportedMediaTypeError(message string) *UnsupportedMediaTypeError {
+	return &UnsupportedMediaTypeError{Message: message}
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return e.Message
+}
+
+func (e *UnsupportedMediaTypeError) Is(target error) bool {
+	return target == ErrUnsupportedMediaType
+}
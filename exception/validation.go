@@ -0,0 +1,136 @@
+package exception
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+)
+
+// ErrValidation matches every ValidationError with errors.Is
+var ErrValidation = errors.New("validation failed")
+
+// Handle error when the request is invalid,
+// either rejected by the validator or malformed like a non numeric id
+type ValidationError struct {
+	Message string
+	Err     error
+	// where the validated payload is in the request body,
+	// "$" unless it is an item of a bulk request like "$[1]"
+	Path string
+	// the in tag of its fields tells where they come from, see jsonPath
+	payload reflect.Type
+}
+
+func NewValidationError(message string) *ValidationError {
+	return &ValidationError{Message: message}
+}
+
+// wrap the errors returned by validator.Struct(payload),
+// every field is described by FieldErrors
+func NewValidationErrorFrom(err validator.ValidationErrors, payload interface{}) *ValidationError {
+	e := &ValidationError{Err: err, Path: "$", payload: reflect.Indirect(reflect.ValueOf(payload)).Type()}
+
+	var fields []string
+	for _, fieldError := range err {
+		fields = append(fields, e.field(fieldError))
+	}
+
+	e.Message = "invalid " + strings.Join(fields, ", ")
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// the fields that failed validation, with their messages in the
+// language of the Accept-Language of request. nil unless err
+// comes from NewValidationErrorFrom
+func FieldErrors(request *http.Request, err error) []web.FieldError {
+	var validationError *ValidationError
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationError) || !errors.As(validationError.Err, &validationErrors) {
+		return nil
+	}
+
+	trans := translatorFor(request)
+
+	fields := make([]web.FieldError, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		name := validationError.field(fieldError)
+		fields[i] = web.FieldError{
+			Field:    name,
+			JSONPath: validationError.jsonPath(fieldError),
+			Rule:     fieldError.Tag(),
+			Param:    fieldError.Param(),
+			// the messages are made with the name validator knows
+			Message: strings.Replace(fieldError.Translate(trans), fieldError.Field(), name, 1),
+		}
+	}
+
+	return fields
+}
+
+// the tag telling where a field of a payload comes from:
+// "query" for the query string, "body" for the whole body,
+// nothing for a member of the body
+func (e *ValidationError) in(fieldError validator.FieldError) string {
+	// "UserFindAllPayload.Filters[password]" is in Filters
+	_, namespace, _ := strings.Cut(fieldError.StructNamespace(), ".")
+	name := strings.FieldsFunc(namespace, func(char rune) bool { return char == '.' || char == '[' })[0]
+
+	field, _ := e.payload.FieldByName(name)
+	return field.Tag.Get("in")
+}
+
+// the name of the field told to the client.
+// a query param is named as in the query string, the values of
+// a repeated param like "sort[1]" are "sort" and a map of params
+// like "filters[password]" gives "password"
+func (e *ValidationError) field(fieldError validator.FieldError) string {
+	if e.in(fieldError) != "query" {
+		return fieldError.Field()
+	}
+
+	name, key, ok := strings.Cut(fieldError.Field(), "[")
+	key = strings.TrimSuffix(key, "]")
+	if _, err := strconv.Atoi(key); ok && err != nil {
+		return key
+	}
+
+	return name
+}
+
+// where the field is in the request body, after Path.
+// "UserCreatePayload.name" is at "$.name",
+// empty when the field isn't in the body
+func (e *ValidationError) jsonPath(fieldError validator.FieldError) string {
+	_, namespace, _ := strings.Cut(fieldError.Namespace(), ".")
+
+	switch e.in(fieldError) {
+	case "query":
+		return ""
+	case "body":
+		// "users[0]" of a bulk request is "$[0]"
+		_, index, _ := strings.Cut(namespace, "[")
+		if index != "" {
+			return e.Path + "[" + index
+		}
+		return e.Path
+	default:
+		return e.Path + "." + namespace
+	}
+}
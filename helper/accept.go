@@ -0,0 +1,98 @@
+package helper
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the quality an Accept header gives to mediaType, from 0 to 1.
+// the most specific range wins, "application/json" over "application/*"
+// over "*/*". an empty header accepts everything
+func AcceptQuality(header string, mediaType string) float64 {
+	if strings.TrimSpace(header) == "" {
+		return 1
+	}
+
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality := 0.0
+	// 0 : nothing matched yet, 1 : */*, 2 : type/*, 3 : exact
+	specificity := 0
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		accepted := strings.ToLower(strings.TrimSpace(params[0]))
+
+		matched := 0
+		switch {
+		case accepted == mediaType:
+			matched = 3
+		case accepted == mainType+"/*":
+			matched = 2
+		case accepted == "*/*":
+			matched = 1
+		}
+		if matched <= specificity {
+			continue
+		}
+
+		specificity = matched
+		quality = qualityOf(params[1:])
+	}
+
+	return quality
+}
+
+// the languages of an Accept-Language header, the preferred first.
+// a regional language is followed by its base, "id-ID" gives "id_id" and "id"
+func AcceptLanguages(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := qualityOf(params[1:])
+		if quality > 0 {
+			languages = append(languages, language{tag: tag, quality: quality})
+		}
+	}
+
+	// the order of the header breaks ties
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	var tags []string
+	for _, language := range languages {
+		base, _, regional := strings.Cut(language.tag, "-")
+		if regional {
+			tags = append(tags, strings.ReplaceAll(language.tag, "-", "_"))
+		}
+		tags = append(tags, base)
+	}
+
+	return tags
+}
+
+// the q parameter of an item of Accept or Accept-Language, 1 when missing
+func qualityOf(params []string) float64 {
+	for _, param := range params {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(value, 64)
+			if err == nil && q >= 0 && q <= 1 {
+				return q
+			}
+		}
+	}
+
+	return 1
+}
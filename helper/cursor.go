@@ -0,0 +1,66 @@
+package helper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Cursor points right after the last item of a page.
+// Sort is the sort order the page was listed with
+// and Values are the last item's values of those sort fields.
+// Query is a hash of the rest of the query the page was listed with,
+// the filters for example, so the cursor is only used with the same ones
+type Cursor struct {
+	Sort   []string `json:"s"`
+	Values []string `json:"v"`
+	Query  string   `json:"q"`
+}
+
+// turn a cursor into an opaque token: base64(json) + "." + base64(hmac).
+// the signature stops clients from crafting their own cursors
+func EncodeCursor(cursor Cursor, secret []byte) string {
+	payload, err := json.Marshal(cursor)
+	PanicIfError(err)
+
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(signCursor(payload, secret))
+}
+
+// read a token made by EncodeCursor.
+// returns an error if the token is malformed or was tampered with
+func DecodeCursor(token string, secret []byte) (Cursor, error) {
+	cursor := Cursor{}
+	encoding := base64.RawURLEncoding
+
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return cursor, errors.New("malformed cursor")
+	}
+
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return cursor, errors.New("malformed cursor")
+	}
+
+	signature, err := encoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, signCursor(payload, secret)) {
+		return cursor, errors.New("invalid cursor signature")
+	}
+
+	err = json.Unmarshal(payload, &cursor)
+	if err != nil {
+		return cursor, errors.New("malformed cursor")
+	}
+
+	return cursor, nil
+}
+
+func signCursor(payload []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
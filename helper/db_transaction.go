@@ -1,15 +1,27 @@
 package helper
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+)
 
-func CommitOrRollback(tx *sql.Tx) {
-	err := recover()
-	if err != nil {
+// commit the transaction if the function returned no error,
+// rollback otherwise. Pass a pointer to the named error result
+// so a failed commit is returned too:
+//
+//	defer helper.CommitOrRollback(tx, &err)
+func CommitOrRollback(tx *sql.Tx, err *error) {
+	recovered := recover()
+	if recovered != nil {
+		tx.Rollback()
+		panic(recovered)
+	}
+
+	if *err != nil {
 		errRollback := tx.Rollback()
-		PanicIfError(errRollback)
-		panic(err)
-	} else {
-		err := tx.Commit()
-		PanicIfError(err)
+		*err = errors.Join(*err, errRollback)
+		return
 	}
+
+	*err = tx.Commit()
 }
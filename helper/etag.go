@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// make an entity tag from the version of an item, e.g. "3"
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// read the versions out of an If-Match header, the item must be in one of them.
+// returns nil and true when the header is empty or "*" as any version matches.
+// returns false when the header can't match any version,
+// e.g. it only has weak tags
+func IfMatchVersions(header string) ([]int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		// If-Match uses strong comparison,
+		// a weak tag like W/"3" never matches
+		if len(tag) < 3 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err == nil && version >= 1 {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, len(versions) > 0
+}
+
+// check an If-None-Match header against the entity tag of an item.
+// If-None-Match uses weak comparison, so W/"3" matches "3" too
+func ETagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,23 @@
+package helper
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// write response as json with the given status code
+func WriteToResponseBody(writer http.ResponseWriter, code int, response interface{}) {
+	WriteToResponseBodyAs(writer, code, "application/json", response)
+}
+
+// write response as json with a json based media type,
+// e.g. application/problem+json
+func WriteToResponseBodyAs(writer http.ResponseWriter, code int, contentType string, response interface{}) {
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(code)
+
+	// the status is already sent,
+	// there is nothing left to do if the client went away
+	encoder := json.NewEncoder(writer)
+	_ = encoder.Encode(response)
+}
@@ -0,0 +1,21 @@
+package helper
+
+import (
+	"context"
+
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+type principalKey struct{}
+
+// store the authenticated caller in ctx
+func WithPrincipal(ctx context.Context, principal domain.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// the caller stored by WithPrincipal.
+// false when the request wasn't authenticated
+func PrincipalFrom(ctx context.Context) (domain.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(domain.Principal)
+	return principal, ok
+}
@@ -0,0 +1,16 @@
+package helper
+
+import "context"
+
+type requestIdKey struct{}
+
+// store the id of the request in ctx
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// the id stored by WithRequestId, empty outside of a request
+func RequestIdFrom(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
@@ -0,0 +1,27 @@
+package helper
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// start a span with the tracer of the global provider. the tracer is
+// looked up on every span so a provider set later is still used,
+// one kept in a package variable stays bound to the first provider
+func StartSpan(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer("github.com/iqbaltaufiq/latihan-restapi").Start(ctx, name, options...)
+}
+
+// end span, marking it failed when err isn't nil.
+// call it deferred with the named error result of the traced func
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -8,36 +8,186 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
 	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/metrics"
 	"github.com/iqbaltaufiq/latihan-restapi/middleware"
-	"github.com/iqbaltaufiq/latihan-restapi/repository"
 	"github.com/iqbaltaufiq/latihan-restapi/router"
 	"github.com/iqbaltaufiq/latihan-restapi/service"
-
-	_ "github.com/go-sql-driver/mysql"
 )
 
 func main() {
-	db := app.NewDB()
-	validate := validator.New()
-	userRepository := repository.NewUserRepository()
-	userService := service.NewUserService(userRepository, db, validate)
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// everything main does, returning instead of exiting
+// so the deferred cleanups run
+func run() error {
+	configPath := flag.String("config", "", "path to a .yaml or .toml config file (default $APP_CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *printConfig {
+		return config.Print(os.Stdout, cfg)
+	}
+
+	// json or text, see log.format.
+	// packages log through slog's default logger
+	logger := app.NewLogger(cfg.Log, os.Stderr)
+	slog.SetDefault(logger)
+
+	// migrate up | down [steps] | status
+	if flag.Arg(0) == "migrate" {
+		return app.RunMigrateCommand(context.Background(), cfg.Database, flag.Args()[1:], os.Stdout)
+	}
+
+	// done on SIGINT or SIGTERM, the server and the purge job stop then
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// none, stdout or otlp, see tracing.exporter
+	shutdownTracing, err := app.StartTracing(context.Background(), cfg.Tracing, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	// mysql, sqlite, postgres or memory, see database.driver
+	storage, err := app.NewStorage(cfg.Database)
+	if err != nil {
+		return err
+	}
+	// closed once the requests in flight are done
+	if storage.DB != nil {
+		defer storage.DB.Close()
+	}
+
+	err = app.MigrateOnStart(context.Background(), storage.DB, cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	// connection pool stats in /metrics
+	if storage.DB != nil {
+		err := metrics.RegisterDB(storage.DB, cfg.Database.Driver)
+		if err != nil {
+			return err
+		}
+	}
+
+	// json field names and translated messages in validation errors
+	validate, err := app.NewValidator()
+	if err != nil {
+		return err
+	}
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte(cfg.Auth.CursorSecret))
 	userController := controller.NewUserController(userService)
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
 
-	httpRouter := router.NewRouter(userController)
+	// nil when no jwt key is configured
+	tokenService, err := app.NewTokenService(cfg.Auth.JWT)
+	if err != nil {
+		return err
+	}
 
-	// apply auth middleware in all routes
-	server := http.Server{
-		Addr:    "localhost:3000",
-		Handler: middleware.NewAuthMiddleware(httpRouter),
+	// database and migrations checks of /readyz.
+	// RunServer drains it when the server shuts down
+	healthService, err := app.NewHealthService(storage.DB, cfg.Database, cfg.Health)
+	if err != nil {
+		return err
 	}
+	healthRouter := router.NewHealthRouter(controller.NewHealthController(healthService))
+
+	// remove soft deleted users in the background
+	app.StartPurgeJob(ctx, userService, cfg.Purge)
+
+	// every client gets rate_limit.requests per rate_limit.period on each route
+	rateLimiter := app.NewRateLimiter(cfg.RateLimit)
+	httpRouter := router.NewRouter(userController, apiKeyController, rateLimiter)
+
+	// apply auth middleware in all routes,
+	// the probes and /metrics are left out of it
+	// request bodies over server.max_body_bytes are refused
+	bodyLimitMiddleware := middleware.NewBodyLimitMiddleware(httpRouter, cfg.Server.MaxBodyBytes)
+	authMiddleware := middleware.NewAuthMiddleware(bodyLimitMiddleware, apiKeyService, tokenService, cfg.Auth.APIKey)
+	// ip addresses failing to authenticate too often are refused before auth
+	failedAuthMiddleware := middleware.NewFailedAuthMiddleware(authMiddleware, rateLimiter)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", failedAuthMiddleware)
+	mux.Handle("/healthz", healthRouter)
+	mux.Handle("/readyz", healthRouter)
+	if cfg.Metrics.Enabled {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+
+	// the logger, the metrics and the trace are outside of auth so rejected
+	// requests are seen too. the request id comes first so every log line has it
+	metricsMiddleware := middleware.NewMetricsMiddleware(mux)
+	loggerMiddleware := middleware.NewLoggerMiddleware(metricsMiddleware, logger)
+	tracingMiddleware := middleware.NewTracingMiddleware(loggerMiddleware)
+	server := app.NewServer(cfg.Server, middleware.NewRequestIdMiddleware(tracingMiddleware))
 
-	err := server.ListenAndServe()
+	// https when server.tls.cert_file is set
+	server.TLSConfig, err = app.NewTLSConfig(cfg.Server.TLS)
 	if err != nil {
-		panic(err)
+		return err
 	}
+
+	listener, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("listening", "addr", listener.Addr().String(), "tls", server.TLSConfig != nil)
+
+	// plain http sent to https, stopped with the server
+	if cfg.Server.TLS.RedirectAddr != "" {
+		redirectServer := app.NewRedirectServer(cfg.Server)
+		defer redirectServer.Close()
+
+		go func() {
+			err := redirectServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("redirect server", "error", err)
+			}
+		}()
+	}
+
+	// until SIGINT or SIGTERM, then the requests in flight are drained.
+	// a second signal kills the process at once
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = app.RunServer(ctx, server, listener, cfg.Server, healthService)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("stopped")
+	return nil
 }
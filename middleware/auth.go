@@ -1,39 +1,106 @@
 package middleware
 
 import (
-	"encoding/json"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
 	"github.com/iqbaltaufiq/latihan-restapi/helper"
-	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
 )
 
 type AuthMiddleware struct {
-	Handler http.Handler
+	Handler       http.Handler
+	APIKeyService service.APIKeyService
+	// verifies "Authorization: Bearer" tokens,
+	// nil when bearer tokens are not accepted
+	TokenService service.TokenService
+	// key from the config with every scope,
+	// to issue the first keys. empty to disable it
+	APIKey string
 }
 
 // make a constructor
 // that will be called in main.go
-func NewAuthMiddleware(handler http.Handler) *AuthMiddleware {
-	return &AuthMiddleware{Handler: handler}
+func NewAuthMiddleware(handler http.Handler, apiKeyService service.APIKeyService, tokenService service.TokenService, apiKey string) *AuthMiddleware {
+	return &AuthMiddleware{Handler: handler, APIKeyService: apiKeyService, TokenService: tokenService, APIKey: apiKey}
 }
 
-// make authentication middleware that checks for "X-API-KEY"
-// this middleware will be placed in ALL routes
-// the hardcoded API key is "SECRET"
+// make authentication middleware that checks for a bearer token,
+// "X-API-KEY" or a verified client certificate, in this order.
+// this middleware will be placed in ALL routes.
+// the caller found from the credentials is put into the request context,
+// see helper.PrincipalFrom
 func (m *AuthMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	if request.Header.Get("X-API-KEY") != "SECRET" {
-		writer.Header().Add("Content-Type", "application/json")
-		writer.WriteHeader(http.StatusUnauthorized)
-		response := web.HttpResponse{
-			Code:   401,
-			Status: "Unauthorized",
-		}
+	var principal domain.Principal
+	var err error
 
-		encoder := json.NewEncoder(writer)
-		err := encoder.Encode(response)
-		helper.PanicIfError(err)
+	if token, ok := bearerToken(request); ok {
+		principal, err = m.authenticateToken(request, token)
+		if err != nil {
+			writer.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		}
+	} else if key := request.Header.Get("X-API-KEY"); key != "" || !hasClientCert(request) {
+		principal, err = m.authenticateAPIKey(request, key)
 	} else {
-		m.Handler.ServeHTTP(writer, request)
+		principal = certificatePrincipal(request)
+	}
+
+	if err != nil {
+		exception.ErrorHandler(writer, request, err)
+		return
+	}
+
+	m.Handler.ServeHTTP(writer, request.WithContext(helper.WithPrincipal(request.Context(), principal)))
+}
+
+func (m *AuthMiddleware) authenticateToken(request *http.Request, token string) (domain.Principal, error) {
+	if m.TokenService == nil {
+		return domain.Principal{}, exception.NewUnauthorizedError("bearer tokens are not accepted")
+	}
+
+	return m.TokenService.Authenticate(request.Context(), token)
+}
+
+func (m *AuthMiddleware) authenticateAPIKey(request *http.Request, key string) (domain.Principal, error) {
+	if key == "" {
+		return domain.Principal{}, exception.NewUnauthorizedError("invalid api key")
+	}
+
+	if m.APIKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(m.APIKey)) == 1 {
+		return domain.Principal{Subject: "admin", Scopes: domain.Scopes}, nil
 	}
+
+	return m.APIKeyService.Authenticate(request.Context(), key)
+}
+
+// a client certificate verified against server.tls.client_ca_file
+func hasClientCert(request *http.Request) bool {
+	return request.TLS != nil && len(request.TLS.VerifiedChains) > 0
+}
+
+// the caller is the common name of the certificate,
+// its organizational units are the roles it has
+func certificatePrincipal(request *http.Request) domain.Principal {
+	subject := request.TLS.VerifiedChains[0][0].Subject
+
+	name := subject.CommonName
+	if name == "" {
+		name = subject.String()
+	}
+
+	return domain.Principal{Subject: name, Scopes: domain.RoleScopes(subject.OrganizationalUnit)}
+}
+
+// the token of "Authorization: Bearer <token>",
+// the scheme is case insensitive
+func bearerToken(request *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(request.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
 }
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/julienschmidt/httprouter"
+)
+
+// wrap a route handle so it only runs for callers
+// granted every one of the scopes, others get 403.
+// the caller is put into the context by AuthMiddleware
+func Authorize(handle httprouter.Handle, scopes ...string) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		principal, ok := helper.PrincipalFrom(request.Context())
+		if !ok {
+			exception.ErrorHandler(writer, request, exception.NewUnauthorizedError("authentication is required"))
+			return
+		}
+
+		var missing []string
+		for _, scope := range scopes {
+			if !principal.HasScope(scope) {
+				missing = append(missing, scope)
+			}
+		}
+
+		if len(missing) > 0 {
+			exception.ErrorHandler(writer, request, exception.NewForbiddenError("missing scope: "+strings.Join(missing, ", ")))
+			return
+		}
+
+		handle(writer, request, params)
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import "net/http"
+
+type BodyLimitMiddleware struct {
+	Handler http.Handler
+	// most bytes a request body may have
+	MaxBytes int64
+}
+
+// make a constructor
+// that will be called in main.go
+func NewBodyLimitMiddleware(handler http.Handler, maxBytes int) *BodyLimitMiddleware {
+	return &BodyLimitMiddleware{Handler: handler, MaxBytes: int64(maxBytes)}
+}
+
+// stop reading a request body after MaxBytes.
+// reading more fails with *http.MaxBytesError, the controllers answer 413 then
+func (m *BodyLimitMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	request.Body = http.MaxBytesReader(writer, request.Body, m.MaxBytes)
+	m.Handler.ServeHTTP(writer, request)
+}
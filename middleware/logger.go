@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/julienschmidt/httprouter"
+)
+
+type LoggerMiddleware struct {
+	Handler http.Handler
+	Logger  *slog.Logger
+}
+
+// make a constructor
+// that will be called in main.go
+func NewLoggerMiddleware(handler http.Handler, logger *slog.Logger) *LoggerMiddleware {
+	return &LoggerMiddleware{Handler: handler, Logger: logger}
+}
+
+// what the route handles tell the middlewares around the router,
+// they only get a copy of the request so it is shared through the context
+type requestInfo struct {
+	route string
+	keyId int
+}
+
+type requestInfoKey struct{}
+
+// the requestInfo of request, added to its context
+// by the first middleware asking for it
+func withRequestInfo(request *http.Request) (*http.Request, *requestInfo) {
+	if info, ok := request.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return request, info
+	}
+
+	info := &requestInfo{}
+	return request.WithContext(context.WithValue(request.Context(), requestInfoKey{}, info)), info
+}
+
+// write one line for every request once it is answered.
+// it wraps AuthMiddleware, so 401s are logged too
+func (m *LoggerMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	start := time.Now()
+	request, info := withRequestInfo(request)
+	recorder := &responseRecorder{ResponseWriter: writer}
+
+	m.Handler.ServeHTTP(recorder, request)
+
+	level := slog.LevelInfo
+	if recorder.Status() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.String("path", request.URL.Path),
+		slog.String("route", info.route),
+		slog.Int("status", recorder.Status()),
+		slog.Duration("latency", time.Since(start)),
+		slog.Int("bytes", recorder.bytes),
+	}
+	if info.keyId != 0 {
+		attrs = append(attrs, slog.Int("key_id", info.keyId))
+	}
+
+	m.Logger.LogAttrs(request.Context(), level, "request", attrs...)
+}
+
+// wrap the handle of route to tell its pattern and the api key
+// of the caller to the access log and the metrics.
+// used by the router on every route
+func Route(route string, handle httprouter.Handle) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		if info, ok := request.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+			info.route = route
+			if principal, ok := helper.PrincipalFrom(request.Context()); ok {
+				info.keyId = principal.KeyId
+			}
+		}
+
+		handle(writer, request, params)
+	}
+}
+
+// keep the status and the size of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(body []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(body)
+	r.bytes += n
+	return n, err
+}
+
+// the status sent, 200 when the handler wrote nothing
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// lets http.ResponseController reach the flusher and deadlines
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/metrics"
+)
+
+type MetricsMiddleware struct {
+	Handler http.Handler
+}
+
+// make a constructor
+// that will be called in main.go
+func NewMetricsMiddleware(handler http.Handler) *MetricsMiddleware {
+	return &MetricsMiddleware{Handler: handler}
+}
+
+// count every request and its duration by route pattern and status.
+// it wraps AuthMiddleware, so 401s are counted too
+func (m *MetricsMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	start := time.Now()
+	request, info := withRequestInfo(request)
+	recorder := &responseRecorder{ResponseWriter: writer}
+
+	m.Handler.ServeHTTP(recorder, request)
+
+	labels := []string{methodLabel(request.Method), info.route, strconv.Itoa(recorder.Status())}
+	metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
+
+// the methods of http, anything else a client sends is counted as OTHER
+// so made up methods don't add series
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
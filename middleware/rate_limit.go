@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/julienschmidt/httprouter"
+)
+
+// a token bucket holding up to Burst requests,
+// refilled with Requests every Period
+type RateLimit struct {
+	// 0 disables rate limiting
+	Requests int
+	Period   time.Duration
+	// Requests when 0
+	Burst int
+}
+
+type RateLimiter struct {
+	// limit of the routes not in Routes
+	Default RateLimit
+	// by method and path of the route, e.g. "GET /api/users/:userId"
+	Routes map[string]RateLimit
+	// failed authentications of an ip address,
+	// see FailedAuthMiddleware
+	FailedAuth RateLimit
+	// read the client ip from X-Forwarded-For instead of the connection
+	TrustForwardedFor bool
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// when the bucket is full again, it can be forgotten after that
+	full time.Time
+}
+
+// make a constructor
+// that will be called in main.go
+func NewRateLimiter(limit RateLimit, routes map[string]RateLimit, failedAuth RateLimit, trustForwardedFor bool) *RateLimiter {
+	return &RateLimiter{
+		Default:           limit,
+		Routes:            routes,
+		FailedAuth:        failedAuth,
+		TrustForwardedFor: trustForwardedFor,
+		buckets:           map[string]*bucket{},
+	}
+}
+
+// false when limit disables rate limiting,
+// the burst of limit is set when it was left to 0
+func (limit *RateLimit) enabled() bool {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return false
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Requests
+	}
+
+	return true
+}
+
+// wrap the handle of route so every client has its own bucket on it.
+// it runs after AuthMiddleware, a request authenticated with an api key
+// is counted against the key, any other caller against its subject.
+// a nil limiter doesn't limit anything
+func (l *RateLimiter) Limit(route string, handle httprouter.Handle) httprouter.Handle {
+	if l == nil {
+		return handle
+	}
+
+	limit, ok := l.Routes[route]
+	if !ok {
+		limit = l.Default
+	}
+	if !limit.enabled() {
+		return handle
+	}
+
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		allowed, remaining, reset, retryAfter := l.take(route+" "+l.client(request), limit, time.Now())
+
+		// draft-ietf-httpapi-ratelimit-headers
+		writer.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		writer.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		writer.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !allowed {
+			writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			exception.ErrorHandler(writer, request, exception.NewTooManyRequestsError("rate limit exceeded, retry in "+strconv.Itoa(retryAfter)+"s"))
+			return
+		}
+
+		handle(writer, request, params)
+	}
+}
+
+// take a token from the bucket of key.
+// reset is the seconds until the bucket is full again,
+// retryAfter the seconds until the next token when none is left
+func (l *RateLimiter) take(key string, limit RateLimit, now time.Time) (allowed bool, remaining int, reset int, retryAfter int) {
+	return l.use(key, limit, now, true)
+}
+
+// like take, without taking the token
+func (l *RateLimiter) peek(key string, limit RateLimit, now time.Time) (allowed bool, retryAfter int) {
+	allowed, _, _, retryAfter = l.use(key, limit, now, false)
+	return allowed, retryAfter
+}
+
+func (l *RateLimiter) use(key string, limit RateLimit, now time.Time, take bool) (allowed bool, remaining int, reset int, retryAfter int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	// tokens per second
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+	burst := float64(limit.Burst)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	allowed = b.tokens >= 1
+	if !allowed {
+		retryAfter = int(math.Ceil((1 - b.tokens) / rate))
+	} else if take {
+		b.tokens--
+	}
+
+	untilFull := (burst - b.tokens) / rate
+	b.full = now.Add(time.Duration(untilFull * float64(time.Second)))
+
+	return allowed, int(b.tokens), int(math.Ceil(untilFull)), retryAfter
+}
+
+// forget the buckets that are full again, once a minute,
+// so clients that went away don't stay in memory
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if !b.full.After(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// the api key of the caller, or its subject for the bootstrap key,
+// bearer tokens and client certificates. its ip address when there is none
+func (l *RateLimiter) client(request *http.Request) string {
+	principal, ok := helper.PrincipalFrom(request.Context())
+	if ok && principal.KeyId != 0 {
+		return "key:" + strconv.Itoa(principal.KeyId)
+	}
+	if ok && principal.Subject != "" {
+		return "subject:" + principal.Subject
+	}
+
+	return l.ip(request)
+}
+
+// the ip address of the caller
+func (l *RateLimiter) ip(request *http.Request) string {
+	if l.TrustForwardedFor {
+		// the last address is the one our proxy appended,
+		// the ones before it are sent by the client and can be anything
+		if values := request.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			addresses := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+				return "ip:" + ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+type FailedAuthMiddleware struct {
+	Handler http.Handler
+	Limiter *RateLimiter
+}
+
+// make a constructor
+// that will be called in main.go
+func NewFailedAuthMiddleware(handler http.Handler, limiter *RateLimiter) *FailedAuthMiddleware {
+	return &FailedAuthMiddleware{Handler: handler, Limiter: limiter}
+}
+
+// count the 401s of every ip address, once Limiter.FailedAuth is used up
+// the address gets 429 before its credentials are checked.
+// it wraps AuthMiddleware, so guessing api keys or tokens is limited too
+func (m *FailedAuthMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	limit := RateLimit{}
+	if m.Limiter != nil {
+		limit = m.Limiter.FailedAuth
+	}
+	if !limit.enabled() {
+		m.Handler.ServeHTTP(writer, request)
+		return
+	}
+
+	key := "failed auth " + m.Limiter.ip(request)
+	if allowed, retryAfter := m.Limiter.peek(key, limit, time.Now()); !allowed {
+		writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		exception.ErrorHandler(writer, request, exception.NewTooManyRequestsError("too many failed authentications, retry in "+strconv.Itoa(retryAfter)+"s"))
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: writer}
+	m.Handler.ServeHTTP(recorder, request)
+
+	if recorder.Status() == http.StatusUnauthorized {
+		m.Limiter.take(key, limit, time.Now())
+	}
+}
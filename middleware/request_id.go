@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+)
+
+type RequestIdMiddleware struct {
+	Handler http.Handler
+}
+
+// make a constructor
+// that will be called in main.go
+func NewRequestIdMiddleware(handler http.Handler) *RequestIdMiddleware {
+	return &RequestIdMiddleware{Handler: handler}
+}
+
+// give every request an id, the X-Request-ID of the client
+// or a random one. it is sent back in X-Request-ID and in error bodies,
+// and every log written with the request context carries it.
+// this middleware is the outermost one
+func (m *RequestIdMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	requestId := request.Header.Get("X-Request-ID")
+	if !validRequestId(requestId) {
+		requestId = newRequestId()
+	}
+
+	writer.Header().Set("X-Request-ID", requestId)
+	m.Handler.ServeHTTP(writer, request.WithContext(helper.WithRequestId(request.Context(), requestId)))
+}
+
+// ids of clients end up in the logs,
+// so only short printable ones without spaces are kept
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] < '!' || requestId[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	// never fails, see crypto/rand.Read
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type TracingMiddleware struct {
+	Handler http.Handler
+}
+
+// make a constructor
+// that will be called in main.go
+func NewTracingMiddleware(handler http.Handler) *TracingMiddleware {
+	return &TracingMiddleware{Handler: handler}
+}
+
+// start the span of the request, continuing the trace
+// of the traceparent header when the caller sent one.
+// the span is named after the route pattern once it is known
+func (m *TracingMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+	ctx, span := helper.StartSpan(ctx, request.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(request.Method), semconv.URLPath(request.URL.Path)),
+	)
+	defer span.End()
+
+	request, info := withRequestInfo(request.WithContext(ctx))
+	recorder := &responseRecorder{ResponseWriter: writer}
+
+	m.Handler.ServeHTTP(recorder, request)
+
+	if info.route != "" {
+		span.SetName(info.route)
+		_, path, _ := strings.Cut(info.route, " ")
+		span.SetAttributes(semconv.HTTPRoute(path))
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.Status()))
+	// 4xx are the caller's fault, not an error of the server
+	if recorder.Status() >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(recorder.Status()))
+	}
+}
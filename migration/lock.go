@@ -0,0 +1,117 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// how long to wait for another instance to finish migrating
+const lockTimeout = time.Minute
+
+// name of the lock taken while migrating.
+// postgres advisory locks take a number instead
+const (
+	lockName = "latihan_restapi_migrate"
+	lockKey  = 7217321806
+)
+
+// locker keeps concurrent migrators of one database apart.
+// the lock belongs to conn, every migration runs on it
+type locker interface {
+	lock(ctx context.Context, conn *sql.Conn) error
+	// release the lock, failed tells whether migrating went wrong
+	unlock(ctx context.Context, conn *sql.Conn, failed bool) error
+	// whether the lock is a transaction,
+	// migrations can't open their own then
+	transactional() bool
+}
+
+func lockerFor(driver string) (locker, error) {
+	switch driver {
+	case "mysql":
+		return mysqlLocker{}, nil
+	case "postgres":
+		return postgresLocker{}, nil
+	case "sqlite":
+		return sqliteLocker{}, nil
+	default:
+		return nil, fmt.Errorf("can't lock driver %s", driver)
+	}
+}
+
+// mysql named lock, held by the session
+type mysqlLocker struct{}
+
+func (mysqlLocker) lock(ctx context.Context, conn *sql.Conn) error {
+	var locked sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	if locked.Int64 != 1 {
+		return errors.New("timed out waiting for another migration to finish")
+	}
+	return nil
+}
+
+func (mysqlLocker) unlock(ctx context.Context, conn *sql.Conn, failed bool) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+	return err
+}
+
+func (mysqlLocker) transactional() bool {
+	return false
+}
+
+// postgres advisory lock, held by the session
+type postgresLocker struct{}
+
+func (postgresLocker) lock(ctx context.Context, conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("timed out waiting for another migration to finish")
+	}
+	return err
+}
+
+func (postgresLocker) unlock(ctx context.Context, conn *sql.Conn, failed bool) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	return err
+}
+
+func (postgresLocker) transactional() bool {
+	return false
+}
+
+// sqlite has no named locks, an immediate transaction takes
+// the write lock of the whole file instead.
+// every migration of the run is committed at once
+// and rolled back at once when one fails.
+// the wait is bounded by busy_timeout in the dsn
+type sqliteLocker struct{}
+
+func (sqliteLocker) lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+	return err
+}
+
+func (sqliteLocker) unlock(ctx context.Context, conn *sql.Conn, failed bool) error {
+	if failed {
+		_, err := conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+
+	_, err := conn.ExecContext(ctx, "COMMIT")
+	return err
+}
+
+func (sqliteLocker) transactional() bool {
+	return true
+}
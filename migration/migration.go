@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// every sql file, one folder per database driver.
+// a migration is a pair of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed sql
+var files embed.FS
+
+// Migration is one versioned change of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// sha256 of Up, to notice a migration edited after being applied
+	Checksum string
+}
+
+// load the migrations of a driver, sorted by version
+func Load(driver string) ([]Migration, error) {
+	dir := path.Join("sql", driver)
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %s", driver)
+	}
+
+	migrations := map[int]*Migration{}
+	for _, entry := range entries {
+		var direction string
+		switch {
+		case strings.HasSuffix(entry.Name(), ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(entry.Name(), ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), "."+direction+".sql")
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version", entry.Name())
+		}
+
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			migrations[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var sorted []Migration
+	for _, migration := range migrations {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down file", migration.Version)
+		}
+		sorted = append(sorted, *migration)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return sorted, nil
+}
+
+// split a migration file into statements.
+// not every driver runs more than one statement at once,
+// so statements must end with a semicolon at the end of a line
+func statements(script string) []string {
+	var statements []string
+	var current []string
+	for _, line := range strings.Split(script, "\n") {
+		current = append(current, line)
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			statement := strings.TrimSpace(strings.Join(current, "\n"))
+			statements = append(statements, strings.TrimSuffix(statement, ";"))
+			current = nil
+		}
+	}
+
+	if statement := strings.TrimSpace(strings.Join(current, "\n")); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
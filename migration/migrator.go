@@ -0,0 +1,355 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/repository"
+)
+
+// the table keeping track of the applied migrations
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT NOT NULL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  checksum VARCHAR(64) NOT NULL,
+  applied_at TIMESTAMP NOT NULL
+)`
+
+// Migrator applies and rolls back the migrations of one database.
+// Only one Migrator works on a database at a time,
+// the others wait for the lock.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+	locker     locker
+	dialect    repository.Dialect
+}
+
+// Status of a migration in the database
+type Status struct {
+	Migration
+	// nil while the migration is pending
+	AppliedAt *time.Time
+	// the migration was edited after being applied
+	Changed bool
+	// applied by a newer build, Migration only has Version and Name
+	Unknown bool
+}
+
+// a row of schema_migrations
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// the part of *sql.Conn and *sql.Tx migrations need
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// create a migrator for the embedded migrations of driver
+func NewMigrator(DB *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := lockerFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	dialect, err := repository.DialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		DB:         DB,
+		Migrations: migrations,
+		locker:     locker,
+		dialect:    dialect,
+	}, nil
+}
+
+// apply every pending migration, oldest first.
+// returns the migrations that were applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := m.run(ctx, conn, migration.Up, func(exec execer) error {
+				_, err := exec.ExecContext(ctx, m.dialect.Rebind("INSERT INTO schema_migrations(version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+					migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// record a migration as applied without running it,
+// for a database whose schema already has what it does
+func (m *Migrator) Baseline(ctx context.Context, version int) (Migration, error) {
+	migration, ok := m.find(version)
+	if !ok {
+		return Migration{}, fmt.Errorf("migration %d is not known by this build", version)
+	}
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if _, ok := applied[version]; ok {
+			return fmt.Errorf("migration %d %s is already applied", migration.Version, migration.Name)
+		}
+
+		_, err = conn.ExecContext(ctx, m.dialect.Rebind("INSERT INTO schema_migrations(version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+		return err
+	})
+
+	return migration, err
+}
+
+// roll back the last steps applied migrations, newest first.
+// returns the migrations that were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		var versions []int
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, version := range versions {
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("migration %d %s is not known by this build, it can't be rolled back", version, applied[version].Name)
+			}
+
+			err := m.run(ctx, conn, migration.Down, func(exec execer) error {
+				_, err := exec.ExecContext(ctx, m.dialect.Rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// the state of every migration known by this build or the database,
+// sorted by version. it only reads, so it works with a read only user
+// and /readyz can call it on every probe. every migration is pending
+// as long as schema_migrations doesn't exist
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	exists, err := m.tableExists(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]appliedMigration{}
+	if exists {
+		applied, err = m.readApplied(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, migration := range m.Migrations {
+		status := Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			status.Changed = row.Checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, row := range applied {
+		row := row
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: row.Version, Name: row.Name},
+			AppliedAt: &row.AppliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// whether schema_migrations was created by Up or Down already
+func (m *Migrator) tableExists(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var query string
+	switch m.dialect.Name() {
+	case "mysql":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'"
+	case "postgresql":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'"
+	default:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	}
+
+	var count int
+	err := conn.QueryRowContext(ctx, query).Scan(&count)
+	return count > 0, err
+}
+
+// run fn on a connection holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = m.locker.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// released even when ctx is done,
+		// otherwise the lock stays with the pooled connection
+		unlockErr := m.locker.unlock(context.Background(), conn, err != nil)
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, createSchemaMigrations)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// read the applied migrations,
+// failing if one of them was edited since
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	applied, err := m.readApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.Migrations {
+		row, ok := applied[migration.Version]
+		if ok && row.Checksum != migration.Checksum {
+			return nil, fmt.Errorf("migration %d %s was changed after it was applied, add a new migration instead", migration.Version, migration.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) readApplied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]appliedMigration{}
+
+	defer rows.Close()
+	for rows.Next() {
+		row := appliedMigration{}
+		err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[row.Version] = row
+	}
+
+	return applied, rows.Err()
+}
+
+// run the statements of script then record them,
+// in a transaction of their own unless the lock already is one.
+// mysql commits every DDL statement right away,
+// so a failed migration there may be half applied
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string, record func(exec execer) error) (err error) {
+	if m.locker.transactional() {
+		return runStatements(ctx, conn, script, record)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer helper.CommitOrRollback(tx, &err)
+
+	return runStatements(ctx, tx, script, record)
+}
+
+func runStatements(ctx context.Context, exec execer, script string, record func(exec execer) error) error {
+	for _, statement := range statements(script) {
+		_, err := exec.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return record(exec)
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
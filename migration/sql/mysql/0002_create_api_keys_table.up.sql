@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+  id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  prefix VARCHAR(16) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  owner VARCHAR(255) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  expires_at DATETIME NULL,
+  revoked_at DATETIME NULL,
+  last_used_at DATETIME NULL,
+  created_at DATETIME NOT NULL,
+  UNIQUE INDEX api_keys_prefix (prefix)
+);
@@ -0,0 +1,3 @@
+DROP INDEX user_owner ON user;
+
+ALTER TABLE user DROP COLUMN owner;
@@ -0,0 +1,5 @@
+DROP INDEX user_deleted_at ON user;
+
+ALTER TABLE user DROP COLUMN deleted_at;
+
+ALTER TABLE user DROP COLUMN version;
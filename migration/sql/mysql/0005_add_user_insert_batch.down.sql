@@ -0,0 +1,3 @@
+DROP INDEX user_insert_batch ON user;
+
+ALTER TABLE user DROP COLUMN insert_batch;
@@ -0,0 +1,5 @@
+-- marks the rows of one multi-row INSERT, mysql has no RETURNING
+-- so their ids are read back with it
+ALTER TABLE user ADD COLUMN insert_batch CHAR(32) NULL;
+
+CREATE INDEX user_insert_batch ON user (insert_batch);
@@ -0,0 +1,7 @@
+-- the table of the first release, kept as it was so databases
+-- that already have it are adopted instead of failing
+CREATE TABLE IF NOT EXISTS "user" (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(200) NOT NULL,
+  occupation VARCHAR(200) NOT NULL
+);
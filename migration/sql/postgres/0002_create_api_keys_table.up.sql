@@ -0,0 +1,14 @@
+CREATE TABLE api_keys (
+  id SERIAL PRIMARY KEY,
+  prefix VARCHAR(16) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  owner VARCHAR(255) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  expires_at TIMESTAMP NULL,
+  revoked_at TIMESTAMP NULL,
+  last_used_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX api_keys_prefix ON api_keys (prefix);
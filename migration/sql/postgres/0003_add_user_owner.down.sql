@@ -0,0 +1,3 @@
+DROP INDEX user_owner;
+
+ALTER TABLE "user" DROP COLUMN owner;
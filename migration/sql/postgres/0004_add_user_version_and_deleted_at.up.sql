@@ -0,0 +1,5 @@
+ALTER TABLE "user" ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE "user" ADD COLUMN deleted_at TIMESTAMP NULL;
+
+CREATE INDEX user_deleted_at ON "user" (deleted_at);
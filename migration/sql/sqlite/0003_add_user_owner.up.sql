@@ -0,0 +1,3 @@
+ALTER TABLE user ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX user_owner ON user (owner);
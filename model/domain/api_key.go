@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+type APIKey struct {
+	Id int
+	// first part of the key, stored as is to find the key
+	Prefix string
+	// sha256 of the whole key, the key itself is never stored
+	Hash string
+	Name string
+	// who the key was issued to
+	Owner  string
+	Scopes []string
+	// nil when the key never expires
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
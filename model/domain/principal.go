@@ -0,0 +1,52 @@
+package domain
+
+// scopes a caller can be granted
+const (
+	ScopeUsersRead   = "users:read"
+	ScopeUsersWrite  = "users:write"
+	ScopeUsersDelete = "users:delete"
+	// manage api keys
+	ScopeAdmin = "admin"
+)
+
+// every scope, in the order they are listed to clients
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeUsersDelete, ScopeAdmin}
+
+// roles are names for a set of scopes
+var Roles = map[string][]string{
+	"viewer": {ScopeUsersRead},
+	"editor": {ScopeUsersRead, ScopeUsersWrite},
+	"owner":  {ScopeUsersRead, ScopeUsersWrite, ScopeUsersDelete},
+	"admin":  Scopes,
+}
+
+// the scopes granted by the roles, unknown roles grant nothing
+func RoleScopes(roles []string) []string {
+	var scopes []string
+	for _, role := range roles {
+		scopes = append(scopes, Roles[role]...)
+	}
+
+	return scopes
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// who the caller is, e.g. the owner of an api key
+	Subject string
+	// the api key used, 0 for other credentials
+	KeyId  int
+	Scopes []string
+	// every claim of the bearer token, nil for api keys
+	Claims map[string]interface{}
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,7 +1,17 @@
 package domain
 
+import "time"
+
 type User struct {
 	Id         int
 	Name       string
 	Occupation string
+	// subject of the caller who created the user,
+	// only they and admins can see it
+	Owner string
+	// increased on every update,
+	// used to detect concurrent updates
+	Version int
+	// set when the user is soft deleted, nil otherwise
+	DeletedAt *time.Time
 }
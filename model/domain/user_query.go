@@ -0,0 +1,23 @@
+package domain
+
+// a struct representing the criteria
+// used by repository when listing users
+type UserQuery struct {
+	// column name as key, exact value to match as value
+	Filters map[string]string
+	Sort    []SortField
+	// values of the Sort fields of the last seen row.
+	// when set, only rows placed after it are returned
+	After  []string
+	Limit  int
+	Offset int
+	// list soft deleted users too
+	IncludeDeleted bool
+	// only list users of this owner, every user when empty
+	Owner string
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
@@ -0,0 +1,13 @@
+package web
+
+import "time"
+
+// a struct representing the incoming request
+// when issuing a new api key
+type APIKeyCreatePayload struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Owner  string   `json:"owner" validate:"required,max=255"`
+	Scopes []string `json:"scopes" validate:"required,min=1,unique,dive,oneof=users:read users:write users:delete admin"`
+	// the key never expires when empty
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,gt"`
+}
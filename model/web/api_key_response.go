@@ -0,0 +1,22 @@
+package web
+
+import "time"
+
+type APIKeyResponse struct {
+	Id         int        `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// the response of issuing a key,
+// the only time the whole key is sent
+type APIKeyIssuedResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
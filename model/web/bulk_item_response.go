@@ -0,0 +1,14 @@
+package web
+
+// outcome of one item of a bulk request.
+// Index is the position of the item in the request
+type BulkItemResponse struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	// the invalid fields of the item
+	Errors []FieldError `json:"errors,omitempty"`
+	// why the item failed, Status and Error are made from it
+	Err error `json:"-"`
+}
@@ -0,0 +1,9 @@
+package web
+
+// paging information sent in HttpResponse.Meta
+// when listing items with a cursor.
+// NextCursor is null on the last page
+type CursorMeta struct {
+	Limit      int     `json:"limit"`
+	NextCursor *string `json:"next_cursor"`
+}
@@ -0,0 +1,16 @@
+package web
+
+// a field of the request that failed validation
+type FieldError struct {
+	// json name of the field, e.g. "name"
+	Field string `json:"field"`
+	// where the field is in the request body, e.g. "$.name" or "$[1].name"
+	// in a bulk request. left out for the query string
+	JSONPath string `json:"json_path,omitempty"`
+	// the validate tag that failed, e.g. "max"
+	Rule string `json:"rule"`
+	// parameter of the rule, e.g. "200" for max=200
+	Param string `json:"param,omitempty"`
+	// the failure in the language of Accept-Language
+	Message string `json:"message"`
+}
@@ -0,0 +1,18 @@
+package web
+
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+type HealthResponse struct {
+	// ok when every check is ok
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
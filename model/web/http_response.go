@@ -4,4 +4,9 @@ type HttpResponse struct {
 	Code   int         `json:"code"`
 	Status string      `json:"status"`
 	Data   interface{} `json:"data"`
+	Meta   interface{} `json:"meta,omitempty"`
+	// set on errors, to match them with the logs
+	RequestId string `json:"request_id,omitempty"`
+	// the invalid fields, when validation failed
+	Errors []FieldError `json:"errors,omitempty"`
 }
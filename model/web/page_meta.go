@@ -0,0 +1,10 @@
+package web
+
+// paging information sent in HttpResponse.Meta
+// alongside a list of items
+type PageMeta struct {
+	Page       int `json:"page"`
+	Size       int `json:"size"`
+	TotalItems int `json:"total_items"`
+	TotalPages int `json:"total_pages"`
+}
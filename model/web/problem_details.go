@@ -0,0 +1,23 @@
+package web
+
+// RFC 7807 media type of ProblemDetails
+const ProblemJSON = "application/problem+json"
+
+// RFC 7807 error response, sent instead of HttpResponse
+// to clients asking for application/problem+json
+type ProblemDetails struct {
+	// identifies the kind of problem, "about:blank" when
+	// there is nothing more to say than the status
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// what went wrong with this request
+	Detail string `json:"detail,omitempty"`
+	// the path the problem happened on
+	Instance string `json:"instance,omitempty"`
+
+	// extension members
+	RequestId string `json:"request_id,omitempty"`
+	// the invalid fields of a validation problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,30 @@
+package web
+
+const (
+	// nothing is saved if any item fails
+	BulkAtomic = "atomic"
+	// the items that pass are saved, the rest are reported
+	BulkBestEffort = "best_effort"
+)
+
+// a struct representing the incoming request
+// when creating many users with POST /api/bulk/users
+// the users are the whole body, the mode comes from ?mode=
+type UserBulkCreatePayload struct {
+	Users []UserCreatePayload `json:"users" in:"body" validate:"required,min=1,max=1000"`
+	Mode  string              `json:"mode" in:"query" validate:"omitempty,oneof=atomic best_effort"`
+}
+
+// a struct representing the incoming request
+// when replacing many users with PUT /api/bulk/users
+type UserBulkUpdatePayload struct {
+	Users []UserUpdatePayload `json:"users" in:"body" validate:"required,min=1,max=1000"`
+	Mode  string              `json:"mode" in:"query" validate:"omitempty,oneof=atomic best_effort"`
+}
+
+// a struct representing the incoming request
+// when deleting many users with DELETE /api/bulk/users
+type UserBulkDeletePayload struct {
+	Ids  []int  `json:"ids" in:"body" validate:"required,min=1,max=1000"`
+	Mode string `json:"mode" in:"query" validate:"omitempty,oneof=atomic best_effort"`
+}
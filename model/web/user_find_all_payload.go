@@ -0,0 +1,18 @@
+package web
+
+// a struct representing the query string
+// when listing users in GET /api/users.
+// offset mode : ?page=2&size=10&sort=name,-id&occupation=student
+// cursor mode : ?after=<next_cursor>&limit=10&sort=name
+// the json tags name the query params in validation errors
+type UserFindAllPayload struct {
+	Page  int      `json:"page" in:"query" validate:"omitempty,excluded_with=After Limit,min=1"`
+	Size  int      `json:"size" in:"query" validate:"omitempty,excluded_with=After Limit,min=1,max=100"`
+	After string   `json:"after" in:"query" validate:"omitempty,max=1000"`
+	Limit int      `json:"limit" in:"query" validate:"omitempty,min=1,max=100"`
+	Sort  []string `json:"sort" in:"query" validate:"dive,oneof=id -id name -name occupation -occupation owner -owner"`
+	// every other param, by name
+	Filters map[string]string `json:"filters" in:"query" validate:"dive,keys,oneof=name occupation owner,endkeys,max=200"`
+	// ?include_deleted=true lists soft deleted users too
+	IncludeDeleted bool `json:"include_deleted" in:"query"`
+}
@@ -0,0 +1,7 @@
+package web
+
+type UserListResponse struct {
+	Users []UserResponse
+	// either PageMeta or CursorMeta
+	Meta interface{}
+}
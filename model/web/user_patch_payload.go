@@ -0,0 +1,20 @@
+package web
+
+const (
+	// RFC 7396, a partial user document, null removes a field
+	MergePatch = "application/merge-patch+json"
+	// RFC 6902, a list of operations like {"op": "replace", "path": "/name", "value": "Jack"}
+	JSONPatch = "application/json-patch+json"
+)
+
+// a struct representing the incoming request
+// when modifying an existing user with PATCH
+type UserPatchPayload struct {
+	Id int
+	// either MergePatch or JSONPatch
+	Type  string
+	Patch []byte
+	// versions the client expects the user to be in, taken from If-Match.
+	// empty means any version
+	Versions []int
+}
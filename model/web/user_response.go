@@ -1,7 +1,12 @@
 package web
 
+import "time"
+
 type UserResponse struct {
-	Id         int    `json:"id"`
-	Name       string `json:"name"`
-	Occupation string `json:"occupation"`
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Occupation string     `json:"occupation"`
+	Owner      string     `json:"owner"`
+	Version    int        `json:"version"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
 }
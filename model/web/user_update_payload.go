@@ -1,6 +1,14 @@
 package web
 
+// a struct representing the incoming request
+// when replacing an existing user with PUT
 type UserUpdatePayload struct {
-	Id   int    `json:"id" validate:"required"`
-	Name string `json:"name" validate:"required,min=1,max=200"`
+	Id         int    `json:"id" validate:"required"`
+	Name       string `json:"name" validate:"required,min=1,max=200"`
+	Occupation string `json:"occupation" validate:"required,min=1,max=200"`
+	// version the client expects the user to be in,
+	// If-Match takes precedence over the body. 0 means any version
+	Version int `json:"version,omitempty" validate:"min=0"`
+	// versions of If-Match, the user must be in one of them
+	IfMatch []int `json:"-"`
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// interface to store api keys.
+// keys are never deleted, only revoked, so they still show who did what
+type APIKeyRepository interface {
+	Save(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	Revoke(ctx context.Context, keyId int, revokedAt time.Time) error
+	Touch(ctx context.Context, keyId int, usedAt time.Time) error
+	FindById(ctx context.Context, keyId int) (domain.APIKey, error)
+	FindByPrefix(ctx context.Context, prefix string) (domain.APIKey, error)
+	FindAll(ctx context.Context) ([]domain.APIKey, error)
+}
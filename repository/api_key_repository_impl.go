@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// APIKeyRepositoryImpl stores api keys in table api_keys,
+// scopes are kept in one column separated by spaces
+type APIKeyRepositoryImpl struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// create a constructor
+// that will be called in main.go
+func NewAPIKeyRepository(DB *sql.DB, Dialect Dialect) APIKeyRepository {
+	return &APIKeyRepositoryImpl{
+		DB:      DB,
+		Dialect: Dialect,
+	}
+}
+
+const apiKeyColumns = "id, prefix, hash, name, owner, scopes, expires_at, revoked_at, last_used_at, created_at"
+
+func (r *APIKeyRepositoryImpl) Save(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	var expiresAt *time.Time
+	if key.ExpiresAt != nil {
+		utc := key.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	statement := r.Dialect.Rebind("INSERT INTO api_keys(prefix, hash, name, owner, scopes, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	args := []interface{}{key.Prefix, key.Hash, key.Name, key.Owner, strings.Join(key.Scopes, " "), expiresAt, key.CreatedAt.UTC()}
+	executor := sqlExecutorFrom(ctx, r.DB)
+
+	var err error
+	if r.Dialect.Returning() {
+		err = executor.QueryRowContext(ctx, statement+" RETURNING id", args...).Scan(&key.Id)
+	} else {
+		var result sql.Result
+		result, err = executor.ExecContext(ctx, statement, args...)
+		if err == nil {
+			var id int64
+			id, err = result.LastInsertId()
+			key.Id = int(id)
+		}
+	}
+
+	if r.Dialect.IsDuplicate(err) {
+		return key, exception.NewConflictError("api key prefix already exists")
+	}
+	return key, err
+}
+
+// revoke a key, a key revoked already keeps its first revocation time
+func (r *APIKeyRepositoryImpl) Revoke(ctx context.Context, keyId int, revokedAt time.Time) error {
+	sql := "UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	_, err := sqlExecutorFrom(ctx, r.DB).ExecContext(ctx, r.Dialect.Rebind(sql), revokedAt.UTC(), keyId)
+	return err
+}
+
+// remember when the key was last used
+func (r *APIKeyRepositoryImpl) Touch(ctx context.Context, keyId int, usedAt time.Time) error {
+	sql := "UPDATE api_keys SET last_used_at = ? WHERE id = ?"
+	_, err := sqlExecutorFrom(ctx, r.DB).ExecContext(ctx, r.Dialect.Rebind(sql), usedAt.UTC(), keyId)
+	return err
+}
+
+func (r *APIKeyRepositoryImpl) FindById(ctx context.Context, keyId int) (domain.APIKey, error) {
+	return r.findOne(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", keyId)
+}
+
+func (r *APIKeyRepositoryImpl) FindByPrefix(ctx context.Context, prefix string) (domain.APIKey, error) {
+	return r.findOne(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", prefix)
+}
+
+func (r *APIKeyRepositoryImpl) FindAll(ctx context.Context) ([]domain.APIKey, error) {
+	rows, err := sqlExecutorFrom(ctx, r.DB).QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []domain.APIKey{}
+
+	defer rows.Close()
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepositoryImpl) findOne(ctx context.Context, sql string, args ...interface{}) (domain.APIKey, error) {
+	rows, err := sqlExecutorFrom(ctx, r.DB).QueryContext(ctx, r.Dialect.Rebind(sql), args...)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	defer rows.Close()
+	if rows.Next() {
+		return scanAPIKey(rows)
+	} else {
+		return domain.APIKey{}, exception.NewNotFoundError("api key not found")
+	}
+}
+
+func scanAPIKey(rows *sql.Rows) (domain.APIKey, error) {
+	key := domain.APIKey{}
+
+	var scopes string
+	err := rows.Scan(&key.Id, &key.Prefix, &key.Hash, &key.Name, &key.Owner, &scopes,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.CreatedAt)
+	key.Scopes = strings.Fields(scopes)
+
+	return key, err
+}
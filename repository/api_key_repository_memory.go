@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// APIKeyRepositoryMemory stores api keys in a MemoryDB
+type APIKeyRepositoryMemory struct {
+	DB *MemoryDB
+}
+
+// create a constructor
+// that will be called in main.go
+func NewAPIKeyRepositoryMemory(DB *MemoryDB) APIKeyRepository {
+	return &APIKeyRepositoryMemory{DB: DB}
+}
+
+func (r *APIKeyRepositoryMemory) Save(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	defer r.DB.lock(ctx)()
+
+	for _, keyInDB := range r.DB.apiKeys {
+		if keyInDB.Prefix == key.Prefix {
+			return key, exception.NewConflictError("api key prefix already exists")
+		}
+	}
+
+	r.DB.lastAPIKeyId++
+	key.Id = r.DB.lastAPIKeyId
+	r.DB.apiKeys[key.Id] = key
+
+	return key, nil
+}
+
+func (r *APIKeyRepositoryMemory) Revoke(ctx context.Context, keyId int, revokedAt time.Time) error {
+	defer r.DB.lock(ctx)()
+
+	key, ok := r.DB.apiKeys[keyId]
+	if ok && key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+		r.DB.apiKeys[keyId] = key
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepositoryMemory) Touch(ctx context.Context, keyId int, usedAt time.Time) error {
+	defer r.DB.lock(ctx)()
+
+	key, ok := r.DB.apiKeys[keyId]
+	if ok {
+		key.LastUsedAt = &usedAt
+		r.DB.apiKeys[keyId] = key
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepositoryMemory) FindById(ctx context.Context, keyId int) (domain.APIKey, error) {
+	defer r.DB.lock(ctx)()
+
+	key, ok := r.DB.apiKeys[keyId]
+	if !ok {
+		return domain.APIKey{}, exception.NewNotFoundError("api key not found")
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepositoryMemory) FindByPrefix(ctx context.Context, prefix string) (domain.APIKey, error) {
+	defer r.DB.lock(ctx)()
+
+	for _, key := range r.DB.apiKeys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+
+	return domain.APIKey{}, exception.NewNotFoundError("api key not found")
+}
+
+func (r *APIKeyRepositoryMemory) FindAll(ctx context.Context) ([]domain.APIKey, error) {
+	defer r.DB.lock(ctx)()
+
+	keys := []domain.APIKey{}
+	for _, key := range r.DB.apiKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Id < keys[j].Id
+	})
+
+	return keys, nil
+}
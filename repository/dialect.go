@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect holds what differs between the sql databases we support.
+// Queries are written with ? placeholders and rebound for the database.
+type Dialect interface {
+	// name of the database, as opentelemetry's db.system
+	Name() string
+	// quote a table or column name
+	Quote(name string) string
+	// turn the ? placeholders into the ones the driver understands
+	Rebind(query string) string
+	// whether INSERT ... RETURNING id is supported,
+	// LastInsertId and an insert_batch column are used otherwise
+	Returning() bool
+	// whether err is a violation of a unique constraint
+	IsDuplicate(err error) bool
+}
+
+// the dialect of a database.driver from the config
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "mysql":
+		return MySQL, nil
+	case "sqlite":
+		return SQLite, nil
+	case "postgres":
+		return PostgreSQL, nil
+	default:
+		return nil, fmt.Errorf("no sql dialect for driver %s", driver)
+	}
+}
+
+// rewrite ? into $1, $2, ... for databases using numbered placeholders.
+// our queries never have a ? inside a string literal
+func rebindNumbered(query string) string {
+	var builder strings.Builder
+	n := 0
+	for _, char := range query {
+		if char == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+		} else {
+			builder.WriteRune(char)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL is the dialect of github.com/go-sql-driver/mysql
+var MySQL Dialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Quote(name string) string {
+	return "`" + name + "`"
+}
+
+func (mysqlDialect) Rebind(query string) string {
+	return query
+}
+
+// mysql has no RETURNING, a single row has its id in LastInsertId.
+// the rows of a multi-row insert are read back by their insert_batch
+func (mysqlDialect) Returning() bool {
+	return false
+}
+
+func (mysqlDialect) IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
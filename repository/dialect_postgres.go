@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostgreSQL is the dialect of github.com/lib/pq
+var PostgreSQL Dialect = postgresDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgresql"
+}
+
+// user is a reserved word in postgres, it must be quoted
+func (postgresDialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+func (postgresDialect) Rebind(query string) string {
+	return rebindNumbered(query)
+}
+
+// lib/pq doesn't support LastInsertId
+func (postgresDialect) Returning() bool {
+	return true
+}
+
+func (postgresDialect) IsDuplicate(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
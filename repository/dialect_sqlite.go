@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is the dialect of modernc.org/sqlite
+var SQLite Dialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+func (sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+// LastInsertId of sqlite is the id of the last row of a multi-row insert,
+// RETURNING gives all of them
+func (sqliteDialect) Returning() bool {
+	return true
+}
+
+func (sqliteDialect) IsDuplicate(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// MemoryDB keeps every table in memory.
+// Everything is lost on restart, it is meant for tests and demos.
+type MemoryDB struct {
+	mu           sync.Mutex
+	users        map[int]domain.User
+	lastUserId   int
+	apiKeys      map[int]domain.APIKey
+	lastAPIKeyId int
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		users:   map[int]domain.User{},
+		apiKeys: map[int]domain.APIKey{},
+	}
+}
+
+// the key the *MemoryDB in transaction is stored under in context
+type memoryTxKey struct{}
+
+// lock the db, unless ctx is inside a transaction of this db
+// that already holds the lock. Returns the function to unlock it.
+func (db *MemoryDB) lock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) == db {
+		return func() {}
+	}
+
+	db.mu.Lock()
+	return db.mu.Unlock
+}
+
+// a copy of every table, to be put back on rollback
+type memorySnapshot struct {
+	users        map[int]domain.User
+	lastUserId   int
+	apiKeys      map[int]domain.APIKey
+	lastAPIKeyId int
+}
+
+func (db *MemoryDB) snapshot() memorySnapshot {
+	users := make(map[int]domain.User, len(db.users))
+	for id, user := range db.users {
+		users[id] = user
+	}
+
+	apiKeys := make(map[int]domain.APIKey, len(db.apiKeys))
+	for id, key := range db.apiKeys {
+		apiKeys[id] = key
+	}
+
+	return memorySnapshot{
+		users:        users,
+		lastUserId:   db.lastUserId,
+		apiKeys:      apiKeys,
+		lastAPIKeyId: db.lastAPIKeyId,
+	}
+}
+
+func (db *MemoryDB) restore(snapshot memorySnapshot) {
+	db.users = snapshot.users
+	db.lastUserId = snapshot.lastUserId
+	db.apiKeys = snapshot.apiKeys
+	db.lastAPIKeyId = snapshot.lastAPIKeyId
+}
+
+// MemoryTransactor runs one transaction at a time on a MemoryDB
+// and puts the tables back the way they were on rollback.
+type MemoryTransactor struct {
+	DB *MemoryDB
+}
+
+// create a constructor
+// that will be called in main.go
+func NewMemoryTransactor(DB *MemoryDB) Transactor {
+	return &MemoryTransactor{DB: DB}
+}
+
+func (t *MemoryTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	// already inside a transaction, join it
+	if ctx.Value(memoryTxKey{}) == t.DB {
+		return fn(ctx)
+	}
+
+	t.DB.mu.Lock()
+	defer t.DB.mu.Unlock()
+
+	snapshot := t.DB.snapshot()
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			t.DB.restore(snapshot)
+			panic(recovered)
+		}
+
+		if err != nil {
+			t.DB.restore(snapshot)
+		}
+	}()
+
+	return fn(context.WithValue(ctx, memoryTxKey{}, t.DB))
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/metrics"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// observe how long a repository method took since start, in metrics.RepositoryDuration
+func observe(repository string, method string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	metrics.RepositoryDuration.WithLabelValues(repository, method, result).Observe(time.Since(start).Seconds())
+}
+
+// UserRepositoryMetrics times every method of Repository
+type UserRepositoryMetrics struct {
+	Repository UserRepository
+}
+
+// create a constructor
+// that will be called in main.go
+func NewUserRepositoryMetrics(repository UserRepository) UserRepository {
+	return &UserRepositoryMetrics{Repository: repository}
+}
+
+func (r *UserRepositoryMetrics) Save(ctx context.Context, user domain.User) (saved domain.User, err error) {
+	defer func(start time.Time) { observe("user", "Save", start, err) }(time.Now())
+	return r.Repository.Save(ctx, user)
+}
+
+func (r *UserRepositoryMetrics) SaveAll(ctx context.Context, users []domain.User) (saved []domain.User, err error) {
+	defer func(start time.Time) { observe("user", "SaveAll", start, err) }(time.Now())
+	return r.Repository.SaveAll(ctx, users)
+}
+
+func (r *UserRepositoryMetrics) Update(ctx context.Context, user domain.User) (updated domain.User, err error) {
+	defer func(start time.Time) { observe("user", "Update", start, err) }(time.Now())
+	return r.Repository.Update(ctx, user)
+}
+
+func (r *UserRepositoryMetrics) Delete(ctx context.Context, user domain.User) (err error) {
+	defer func(start time.Time) { observe("user", "Delete", start, err) }(time.Now())
+	return r.Repository.Delete(ctx, user)
+}
+
+func (r *UserRepositoryMetrics) DeleteAll(ctx context.Context, userIds []int) (err error) {
+	defer func(start time.Time) { observe("user", "DeleteAll", start, err) }(time.Now())
+	return r.Repository.DeleteAll(ctx, userIds)
+}
+
+func (r *UserRepositoryMetrics) Restore(ctx context.Context, user domain.User) (restored domain.User, err error) {
+	defer func(start time.Time) { observe("user", "Restore", start, err) }(time.Now())
+	return r.Repository.Restore(ctx, user)
+}
+
+func (r *UserRepositoryMetrics) Purge(ctx context.Context, deletedBefore time.Time) (purged int, err error) {
+	defer func(start time.Time) { observe("user", "Purge", start, err) }(time.Now())
+	return r.Repository.Purge(ctx, deletedBefore)
+}
+
+func (r *UserRepositoryMetrics) FindById(ctx context.Context, userId int, owner string, includeDeleted bool) (user domain.User, err error) {
+	defer func(start time.Time) { observe("user", "FindById", start, err) }(time.Now())
+	return r.Repository.FindById(ctx, userId, owner, includeDeleted)
+}
+
+func (r *UserRepositoryMetrics) FindAll(ctx context.Context, query domain.UserQuery) (users []domain.User, err error) {
+	defer func(start time.Time) { observe("user", "FindAll", start, err) }(time.Now())
+	return r.Repository.FindAll(ctx, query)
+}
+
+func (r *UserRepositoryMetrics) Count(ctx context.Context, query domain.UserQuery) (count int, err error) {
+	defer func(start time.Time) { observe("user", "Count", start, err) }(time.Now())
+	return r.Repository.Count(ctx, query)
+}
+
+// APIKeyRepositoryMetrics times every method of Repository
+type APIKeyRepositoryMetrics struct {
+	Repository APIKeyRepository
+}
+
+// create a constructor
+// that will be called in main.go
+func NewAPIKeyRepositoryMetrics(repository APIKeyRepository) APIKeyRepository {
+	return &APIKeyRepositoryMetrics{Repository: repository}
+}
+
+func (r *APIKeyRepositoryMetrics) Save(ctx context.Context, key domain.APIKey) (saved domain.APIKey, err error) {
+	defer func(start time.Time) { observe("api_key", "Save", start, err) }(time.Now())
+	return r.Repository.Save(ctx, key)
+}
+
+func (r *APIKeyRepositoryMetrics) Revoke(ctx context.Context, keyId int, revokedAt time.Time) (err error) {
+	defer func(start time.Time) { observe("api_key", "Revoke", start, err) }(time.Now())
+	return r.Repository.Revoke(ctx, keyId, revokedAt)
+}
+
+func (r *APIKeyRepositoryMetrics) Touch(ctx context.Context, keyId int, usedAt time.Time) (err error) {
+	defer func(start time.Time) { observe("api_key", "Touch", start, err) }(time.Now())
+	return r.Repository.Touch(ctx, keyId, usedAt)
+}
+
+func (r *APIKeyRepositoryMetrics) FindById(ctx context.Context, keyId int) (key domain.APIKey, err error) {
+	defer func(start time.Time) { observe("api_key", "FindById", start, err) }(time.Now())
+	return r.Repository.FindById(ctx, keyId)
+}
+
+func (r *APIKeyRepositoryMetrics) FindByPrefix(ctx context.Context, prefix string) (key domain.APIKey, err error) {
+	defer func(start time.Time) { observe("api_key", "FindByPrefix", start, err) }(time.Now())
+	return r.Repository.FindByPrefix(ctx, prefix)
+}
+
+func (r *APIKeyRepositoryMetrics) FindAll(ctx context.Context) (keys []domain.APIKey, err error) {
+	defer func(start time.Time) { observe("api_key", "FindAll", start, err) }(time.Now())
+	return r.Repository.FindAll(ctx)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transactor runs fn inside a transaction of the storage backend.
+// Repositories called with the ctx given to fn take part in that transaction.
+// The transaction is committed when fn returns nil and rolled back otherwise.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// the key the *sql.Tx is stored under in context
+type sqlTxKey struct{}
+
+type SQLTransactor struct {
+	DB *sql.DB
+}
+
+// create a constructor
+// that will be called in main.go
+func NewSQLTransactor(DB *sql.DB) Transactor {
+	return &SQLTransactor{DB: DB}
+}
+
+func (t *SQLTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	// already inside a transaction, join it
+	if _, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	// the span covers the commit too, so it is ended after it
+	ctx, span := helper.StartSpan(ctx, "transaction")
+	defer func() { helper.EndSpan(span, err) }()
+
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer helper.CommitOrRollback(tx, &err)
+
+	return fn(context.WithValue(ctx, sqlTxKey{}, tx))
+}
+
+// what both *sql.DB and *sql.Tx can do
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// the transaction started by SQLTransactor,
+// or db itself when called outside of a transaction
+func sqlExecutorFrom(ctx context.Context, db *sql.DB) sqlExecutor {
+	if tx, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// start the span of a sql statement, named after its first keyword
+// like SELECT or UPDATE. the arguments are left out, they may be personal data
+func startSQLSpan(ctx context.Context, dialect Dialect, statement string) (context.Context, trace.Span) {
+	operation, _, _ := strings.Cut(statement, " ")
+	return helper.StartSpan(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(dialect.Name()),
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(statement),
+		),
+	)
+}
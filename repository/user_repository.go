@@ -2,16 +2,24 @@ package repository
 
 import (
 	"context"
-	"database/sql"
+	"time"
 
 	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
 )
 
-// interface untuk berinteraksi dengan database (domain)
+// interface untuk berinteraksi dengan database (domain).
+// Methods join the transaction of ctx when called within Transactor,
+// so implementations are free to store users anywhere.
 type UserRepository interface {
-	Save(ctx context.Context, tx *sql.Tx, user domain.User) domain.User
-	Update(ctx context.Context, tx *sql.Tx, user domain.User) domain.User
-	Delete(ctx context.Context, tx *sql.Tx, userId int)
-	FindById(ctx context.Context, tx *sql.Tx, userId int) (domain.User, error)
-	FindAll(ctx context.Context, tx *sql.Tx) []domain.User
+	Save(ctx context.Context, user domain.User) (domain.User, error)
+	SaveAll(ctx context.Context, users []domain.User) ([]domain.User, error)
+	Update(ctx context.Context, user domain.User) (domain.User, error)
+	Delete(ctx context.Context, user domain.User) error
+	DeleteAll(ctx context.Context, userIds []int) error
+	Restore(ctx context.Context, user domain.User) (domain.User, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
+	// owner limits the search to the users of owner, empty means any owner
+	FindById(ctx context.Context, userId int, owner string, includeDeleted bool) (domain.User, error)
+	FindAll(ctx context.Context, query domain.UserQuery) ([]domain.User, error)
+	Count(ctx context.Context, query domain.UserQuery) (int, error)
 }
@@ -2,88 +2,522 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
-	"errors"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
 	"github.com/iqbaltaufiq/latihan-restapi/helper"
 	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
 )
 
+// UserRepositoryImpl stores users in a sql database,
+// Dialect takes care of the differences between mysql, sqlite and postgres
 type UserRepositoryImpl struct {
+	DB      *sql.DB
+	Dialect Dialect
 }
 
 // create a constructor
 // that will be called in main.go
-func NewUserRepository() UserRepository {
-	return &UserRepositoryImpl{}
+func NewUserRepository(DB *sql.DB, Dialect Dialect) UserRepository {
+	return &UserRepositoryImpl{
+		DB:      DB,
+		Dialect: Dialect,
+	}
 }
 
 // insert user into table user.
 // Save takes user object from service to be inserted into database
-func (r *UserRepositoryImpl) Save(ctx context.Context, tx *sql.Tx, user domain.User) domain.User {
-	// lakukan query ke DB
-	sql := "INSERT INTO user(name, occupation) VALUES (?,?)"
-	result, err := tx.ExecContext(ctx, sql, user.Name, user.Occupation)
-	helper.PanicIfError(err)
+func (r *UserRepositoryImpl) Save(ctx context.Context, user domain.User) (domain.User, error) {
+	users, err := r.SaveAll(ctx, []domain.User{user})
+	if err != nil {
+		return user, err
+	}
 
-	id, err := result.LastInsertId()
-	helper.PanicIfError(err)
+	return users[0], nil
+}
+
+// how many users are inserted by one statement in SaveAll,
+// keeps the statement far from the placeholder limit
+const userInsertBatchSize = 500
+
+// insert many users with multi-row INSERT statements.
+// without RETURNING the rows of a statement share an insert_batch
+// and their ids are read back with it, see insertBatch
+func (r *UserRepositoryImpl) SaveAll(ctx context.Context, users []domain.User) ([]domain.User, error) {
+	for start := 0; start < len(users); start += userInsertBatchSize {
+		end := start + userInsertBatchSize
+		if end > len(users) {
+			end = len(users)
+		}
+		batch := users[start:end]
+
+		// a single row has its id in LastInsertId
+		marker := ""
+		columns := "name, occupation, owner"
+		placeholders := "(?,?,?)"
+		if !r.Dialect.Returning() && len(batch) > 1 {
+			marker = newInsertBatch()
+			columns += ", insert_batch"
+			placeholders = "(?,?,?,?)"
+		}
 
-	user.Id = int(id)
-	return user
+		values := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*4)
+		for i, user := range batch {
+			values[i] = placeholders
+			args = append(args, user.Name, user.Occupation, user.Owner)
+			if marker != "" {
+				args = append(args, marker)
+			}
+		}
+
+		sql := "INSERT INTO " + r.table() + "(" + columns + ") VALUES " + strings.Join(values, ",")
+
+		var ids []int
+		var err error
+		if marker != "" {
+			ids, err = r.insertBatch(ctx, sql, marker, len(batch), args...)
+		} else {
+			ids, err = r.insert(ctx, sql, args...)
+		}
+		if err != nil {
+			return nil, r.writeError(err)
+		}
+
+		for i := range batch {
+			batch[i].Id = ids[i]
+			batch[i].Version = 1
+		}
+	}
+
+	return users, nil
 }
 
-// update user's name
-func (r *UserRepositoryImpl) Update(ctx context.Context, tx *sql.Tx, user domain.User) domain.User {
-	sql := "UPDATE user SET name = ? WHERE id = ?"
-	_, err := tx.ExecContext(ctx, sql, user.Name, user.Id)
-	helper.PanicIfError(err)
+// update every field of user except id.
+// the update only happens if the version in database
+// is still user.Version, so a concurrent update is never overwritten
+func (r *UserRepositoryImpl) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	sql := "UPDATE " + r.table() + " SET name = ?, occupation = ?, version = version + 1 WHERE id = ? AND version = ?"
+	result, err := r.exec(ctx, sql, user.Name, user.Occupation, user.Id, user.Version)
+	if err != nil {
+		return user, r.writeError(err)
+	}
+
+	err = checkVersionMatched(result)
+	if err != nil {
+		return user, err
+	}
 
-	return user
+	user.Version++
+	return user, nil
 }
 
-// delete a user
-func (r *UserRepositoryImpl) Delete(ctx context.Context, tx *sql.Tx, userId int) {
-	sql := "DELETE FROM user WHERE id = ?"
-	_, err := tx.ExecContext(ctx, sql, userId)
-	helper.PanicIfError(err)
+// soft delete a user, if it is still in user.Version.
+// the row is kept until Purge removes it
+func (r *UserRepositoryImpl) Delete(ctx context.Context, user domain.User) error {
+	sql := "UPDATE " + r.table() + " SET deleted_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	result, err := r.exec(ctx, sql, time.Now().UTC(), user.Id, user.Version)
+	if err != nil {
+		return err
+	}
+
+	return checkVersionMatched(result)
 }
 
-// get user by id
-func (r *UserRepositoryImpl) FindById(ctx context.Context, tx *sql.Tx, userId int) (domain.User, error) {
-	sql := "SELECT * FROM user WHERE id = ?"
-	rows, err := tx.QueryContext(ctx, sql, userId)
-	helper.PanicIfError(err)
+// soft delete many users at once, whatever their version is
+func (r *UserRepositoryImpl) DeleteAll(ctx context.Context, userIds []int) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(userIds))
+	args := []interface{}{time.Now().UTC()}
+	for i, userId := range userIds {
+		placeholders[i] = "?"
+		args = append(args, userId)
+	}
+
+	sql := "UPDATE " + r.table() + " SET deleted_at = ?, version = version + 1 WHERE deleted_at IS NULL AND id IN (" + strings.Join(placeholders, ",") + ")"
+	_, err := r.exec(ctx, sql, args...)
+	return err
+}
+
+// bring back a soft deleted user
+func (r *UserRepositoryImpl) Restore(ctx context.Context, user domain.User) (domain.User, error) {
+	sql := "UPDATE " + r.table() + " SET deleted_at = NULL, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NOT NULL"
+	result, err := r.exec(ctx, sql, user.Id, user.Version)
+	if err != nil {
+		return user, err
+	}
+
+	err = checkVersionMatched(result)
+	if err != nil {
+		return user, err
+	}
+
+	user.DeletedAt = nil
+	user.Version++
+	return user, nil
+}
+
+// hard delete users soft deleted before the given time
+func (r *UserRepositoryImpl) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	sql := "DELETE FROM " + r.table() + " WHERE deleted_at IS NOT NULL AND deleted_at < ?"
+	result, err := r.exec(ctx, sql, deletedBefore.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// no affected row means the version was changed
+// by another request since the user was read
+func checkVersionMatched(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return exception.NewPreconditionFailedError("user was modified by another request")
+	}
+	return nil
+}
+
+// get user by id.
+// a soft deleted user is only returned with includeDeleted,
+// a user of another owner is never returned
+func (r *UserRepositoryImpl) FindById(ctx context.Context, userId int, owner string, includeDeleted bool) (domain.User, error) {
+	sql := "SELECT " + userSelectColumns + " FROM " + r.table() + " WHERE id = ?"
+	args := []interface{}{userId}
+	if owner != "" {
+		sql += " AND owner = ?"
+		args = append(args, owner)
+	}
+	if !includeDeleted {
+		sql += " AND deleted_at IS NULL"
+	}
+
+	rows, err := r.query(ctx, sql, args...)
+	if err != nil {
+		return domain.User{}, err
+	}
 
 	user := domain.User{}
 
 	defer rows.Close()
 	if rows.Next() {
-		err := rows.Scan(&user.Id, &user.Name, &user.Occupation)
-		helper.PanicIfError(err)
-
-		return user, nil
+		err := rows.Scan(&user.Id, &user.Name, &user.Occupation, &user.Owner, &user.Version, &user.DeletedAt)
+		return user, err
 	} else {
-		return user, errors.New("RepositoryError: User not found")
+		return user, exception.NewNotFoundError("user not found")
 	}
 }
 
-// get all users
-func (r *UserRepositoryImpl) FindAll(ctx context.Context, tx *sql.Tx) []domain.User {
-	sql := "SELECT * FROM user"
-	rows, err := tx.QueryContext(ctx, sql)
-	helper.PanicIfError(err)
+// get users matching the query, one page at a time
+func (r *UserRepositoryImpl) FindAll(ctx context.Context, query domain.UserQuery) ([]domain.User, error) {
+	where, args := userWhereClause(query)
+	sql := "SELECT " + userSelectColumns + " FROM " + r.table() + where + userOrderClause(query)
+	if query.Limit > 0 {
+		sql += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := r.query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
 
 	users := []domain.User{}
 
 	defer rows.Close()
 	for rows.Next() {
 		user := domain.User{}
-		err := rows.Scan(&user.Id, &user.Name, &user.Occupation)
-		helper.PanicIfError(err)
+		err := rows.Scan(&user.Id, &user.Name, &user.Occupation, &user.Owner, &user.Version, &user.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
 
 		users = append(users, user)
 	}
 
-	return users
+	return users, rows.Err()
+}
+
+// count users matching the query filters, ignoring paging
+func (r *UserRepositoryImpl) Count(ctx context.Context, query domain.UserQuery) (int, error) {
+	where, args := userWhereClause(query)
+	sql := "SELECT COUNT(*) FROM " + r.table() + where
+
+	var total int
+	err := r.queryRow(ctx, sql, args...).Scan(&total)
+	return total, err
+}
+
+// user is a reserved word in some databases
+func (r *UserRepositoryImpl) table() string {
+	return r.Dialect.Quote("user")
+}
+
+// run a statement in the transaction of ctx
+func (r *UserRepositoryImpl) exec(ctx context.Context, sql string, args ...interface{}) (result sql.Result, err error) {
+	slog.DebugContext(ctx, "user repository exec", "sql", sql)
+	ctx, span := startSQLSpan(ctx, r.Dialect, sql)
+	defer func() { helper.EndSpan(span, err) }()
+
+	return sqlExecutorFrom(ctx, r.DB).ExecContext(ctx, r.Dialect.Rebind(sql), args...)
+}
+
+// run a query in the transaction of ctx
+// the span ends once the query is sent, reading the rows isn't part of it
+func (r *UserRepositoryImpl) query(ctx context.Context, sql string, args ...interface{}) (rows *sql.Rows, err error) {
+	slog.DebugContext(ctx, "user repository query", "sql", sql)
+	ctx, span := startSQLSpan(ctx, r.Dialect, sql)
+	defer func() { helper.EndSpan(span, err) }()
+
+	return sqlExecutorFrom(ctx, r.DB).QueryContext(ctx, r.Dialect.Rebind(sql), args...)
+}
+
+// run a query returning a single row in the transaction of ctx
+func (r *UserRepositoryImpl) queryRow(ctx context.Context, sql string, args ...interface{}) *sql.Row {
+	slog.DebugContext(ctx, "user repository query", "sql", sql)
+	ctx, span := startSQLSpan(ctx, r.Dialect, sql)
+
+	row := sqlExecutorFrom(ctx, r.DB).QueryRowContext(ctx, r.Dialect.Rebind(sql), args...)
+	helper.EndSpan(span, row.Err())
+	return row
+}
+
+// run an INSERT and return the ids of its rows in order.
+// without RETURNING the INSERT must be of a single row, see insertBatch
+func (r *UserRepositoryImpl) insert(ctx context.Context, sql string, args ...interface{}) ([]int, error) {
+	var ids []int
+
+	if r.Dialect.Returning() {
+		rows, err := r.query(ctx, sql+" RETURNING id", args...)
+		if err != nil {
+			return nil, err
+		}
+
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			err := rows.Scan(&id)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, rows.Err()
+	}
+
+	result, err := r.exec(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ids, int(id)), nil
+}
+
+// run a multi-row INSERT giving every row the insert_batch marker
+// and return the ids of its rows in order.
+// mysql only tells the id of the first row and the next ones aren't
+// always consecutive, e.g. with innodb_autoinc_lock_mode=2,
+// so they are selected back. the rows of one statement get
+// increasing ids, sorting by id keeps the order of VALUES
+func (r *UserRepositoryImpl) insertBatch(ctx context.Context, sql string, marker string, count int, args ...interface{}) ([]int, error) {
+	_, err := r.exec(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.query(ctx, "SELECT id FROM "+r.table()+" WHERE insert_batch = ? ORDER BY id", marker)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) != count {
+		return nil, fmt.Errorf("inserted %d users but found %d of insert batch %s", count, len(ids), marker)
+	}
+	return ids, nil
+}
+
+// a random marker for the rows of one INSERT
+func newInsertBatch() string {
+	marker := make([]byte, 16)
+	_, err := rand.Read(marker)
+	helper.PanicIfError(err)
+
+	return hex.EncodeToString(marker)
+}
+
+// turn a duplicate key error into ConflictError,
+// other errors are returned as is
+func (r *UserRepositoryImpl) writeError(err error) error {
+	if r.Dialect.IsDuplicate(err) {
+		return exception.NewConflictError("user already exists")
+	}
+
+	return err
+}
+
+// columns read into domain.User, in the order they are scanned
+const userSelectColumns = "id, name, occupation, owner, version, deleted_at"
+
+// columns of table user that can be used to filter and sort.
+// anything coming from the query string is looked up here
+// so it never reaches the sql string as is.
+var userColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"occupation": "occupation",
+	"owner":      "owner",
+}
+
+// build " WHERE col = ? AND ..." from the query filters,
+// hiding soft deleted users unless asked
+// and, when After is set, the keyset condition.
+// filters are applied in alphabetical order
+// so the same query always produces the same sql
+func userWhereClause(query domain.UserQuery) (string, []interface{}) {
+	fields := make([]string, 0, len(query.Filters))
+	for field := range query.Filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var conditions []string
+	var args []interface{}
+	for _, field := range fields {
+		column, ok := userColumns[field]
+		if !ok {
+			continue
+		}
+
+		conditions = append(conditions, column+" = ?")
+		args = append(args, query.Filters[field])
+	}
+
+	if query.Owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, query.Owner)
+	}
+
+	if !query.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if len(query.After) > 0 {
+		condition, keysetArgs := userKeysetCondition(query)
+		conditions = append(conditions, condition)
+		args = append(args, keysetArgs...)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// build the condition selecting rows placed after query.After
+// in the query sort order, e.g. sorted by name then id:
+// (name > ? OR (name = ? AND id > ?))
+// with only id it is simply (id > ?)
+// so the database can seek using the index instead of skipping rows
+func userKeysetCondition(query domain.UserQuery) (string, []interface{}) {
+	orders := userOrderColumns(query)
+
+	var alternatives []string
+	var args []interface{}
+	for i, order := range orders {
+		if i >= len(query.After) {
+			break
+		}
+
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, orders[j].Field+" = ?")
+			args = append(args, query.After[j])
+		}
+
+		if order.Desc {
+			parts = append(parts, order.Field+" < ?")
+		} else {
+			parts = append(parts, order.Field+" > ?")
+		}
+		args = append(args, query.After[i])
+
+		alternatives = append(alternatives, strings.Join(parts, " AND "))
+	}
+
+	if len(alternatives) == 1 {
+		return "(" + alternatives[0] + ")", args
+	}
+	return "((" + strings.Join(alternatives, ") OR (") + "))", args
+}
+
+// build " ORDER BY ..." from the query sort fields
+func userOrderClause(query domain.UserQuery) string {
+	var orders []string
+	for _, order := range userOrderColumns(query) {
+		if order.Desc {
+			orders = append(orders, order.Field+" DESC")
+		} else {
+			orders = append(orders, order.Field+" ASC")
+		}
+	}
+
+	return " ORDER BY " + strings.Join(orders, ", ")
+}
+
+// map the query sort fields into columns.
+// id is always added last so the order is stable
+// when the other columns have duplicate values
+func userOrderColumns(query domain.UserQuery) []domain.SortField {
+	var orders []domain.SortField
+	sortedById := false
+	for _, field := range query.Sort {
+		column, ok := userColumns[field.Field]
+		if !ok {
+			continue
+		}
+
+		if column == "id" {
+			sortedById = true
+		}
+		orders = append(orders, domain.SortField{Field: column, Desc: field.Desc})
+	}
+
+	if !sortedById {
+		orders = append(orders, domain.SortField{Field: "id"})
+	}
+	return orders
 }
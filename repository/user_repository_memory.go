@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// UserRepositoryMemory stores users in a MemoryDB.
+// It behaves like UserRepositoryImpl, filters, sorting,
+// cursors and soft delete included.
+type UserRepositoryMemory struct {
+	DB *MemoryDB
+}
+
+// create a constructor
+// that will be called in main.go
+func NewUserRepositoryMemory(DB *MemoryDB) UserRepository {
+	return &UserRepositoryMemory{DB: DB}
+}
+
+func (r *UserRepositoryMemory) Save(ctx context.Context, user domain.User) (domain.User, error) {
+	users, err := r.SaveAll(ctx, []domain.User{user})
+	if err != nil {
+		return user, err
+	}
+
+	return users[0], nil
+}
+
+func (r *UserRepositoryMemory) SaveAll(ctx context.Context, users []domain.User) ([]domain.User, error) {
+	defer r.DB.lock(ctx)()
+
+	for i := range users {
+		r.DB.lastUserId++
+		users[i].Id = r.DB.lastUserId
+		users[i].Version = 1
+		users[i].DeletedAt = nil
+		r.DB.users[users[i].Id] = users[i]
+	}
+
+	return users, nil
+}
+
+func (r *UserRepositoryMemory) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	defer r.DB.lock(ctx)()
+
+	userInDB, ok := r.DB.users[user.Id]
+	if !ok || userInDB.Version != user.Version {
+		return user, exception.NewPreconditionFailedError("user was modified by another request")
+	}
+
+	userInDB.Name = user.Name
+	userInDB.Occupation = user.Occupation
+	userInDB.Version++
+	r.DB.users[user.Id] = userInDB
+
+	return userInDB, nil
+}
+
+func (r *UserRepositoryMemory) Delete(ctx context.Context, user domain.User) error {
+	defer r.DB.lock(ctx)()
+
+	userInDB, ok := r.DB.users[user.Id]
+	if !ok || userInDB.Version != user.Version || userInDB.DeletedAt != nil {
+		return exception.NewPreconditionFailedError("user was modified by another request")
+	}
+
+	now := time.Now().UTC()
+	userInDB.DeletedAt = &now
+	userInDB.Version++
+	r.DB.users[user.Id] = userInDB
+
+	return nil
+}
+
+func (r *UserRepositoryMemory) DeleteAll(ctx context.Context, userIds []int) error {
+	defer r.DB.lock(ctx)()
+
+	now := time.Now().UTC()
+	for _, userId := range userIds {
+		userInDB, ok := r.DB.users[userId]
+		if !ok || userInDB.DeletedAt != nil {
+			continue
+		}
+
+		userInDB.DeletedAt = &now
+		userInDB.Version++
+		r.DB.users[userId] = userInDB
+	}
+
+	return nil
+}
+
+func (r *UserRepositoryMemory) Restore(ctx context.Context, user domain.User) (domain.User, error) {
+	defer r.DB.lock(ctx)()
+
+	userInDB, ok := r.DB.users[user.Id]
+	if !ok || userInDB.Version != user.Version || userInDB.DeletedAt == nil {
+		return user, exception.NewPreconditionFailedError("user was modified by another request")
+	}
+
+	userInDB.DeletedAt = nil
+	userInDB.Version++
+	r.DB.users[user.Id] = userInDB
+
+	return userInDB, nil
+}
+
+func (r *UserRepositoryMemory) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.DB.lock(ctx)()
+
+	purged := 0
+	for userId, user := range r.DB.users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(deletedBefore) {
+			delete(r.DB.users, userId)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (r *UserRepositoryMemory) FindById(ctx context.Context, userId int, owner string, includeDeleted bool) (domain.User, error) {
+	defer r.DB.lock(ctx)()
+
+	user, ok := r.DB.users[userId]
+	if !ok || (owner != "" && user.Owner != owner) || (user.DeletedAt != nil && !includeDeleted) {
+		return domain.User{}, exception.NewNotFoundError("user not found")
+	}
+
+	return user, nil
+}
+
+func (r *UserRepositoryMemory) FindAll(ctx context.Context, query domain.UserQuery) ([]domain.User, error) {
+	defer r.DB.lock(ctx)()
+
+	users := r.filter(query)
+
+	orders := userOrderColumns(query)
+	sort.Slice(users, func(i, j int) bool {
+		return compareUsers(users[i], users[j], orders) < 0
+	})
+
+	if query.Offset >= len(users) {
+		return []domain.User{}, nil
+	}
+	users = users[query.Offset:]
+
+	if query.Limit > 0 && query.Limit < len(users) {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+func (r *UserRepositoryMemory) Count(ctx context.Context, query domain.UserQuery) (int, error) {
+	defer r.DB.lock(ctx)()
+
+	return len(r.filter(query)), nil
+}
+
+// users matching the filters, soft delete and cursor of the query
+func (r *UserRepositoryMemory) filter(query domain.UserQuery) []domain.User {
+	orders := userOrderColumns(query)
+
+	users := []domain.User{}
+	for _, user := range r.DB.users {
+		if user.DeletedAt != nil && !query.IncludeDeleted {
+			continue
+		}
+		if query.Owner != "" && user.Owner != query.Owner {
+			continue
+		}
+
+		matched := true
+		for field, value := range query.Filters {
+			if _, ok := userColumns[field]; ok && userField(user, field) != value {
+				matched = false
+			}
+		}
+
+		// keep only users placed after the cursor,
+		// like the keyset condition of UserRepositoryImpl
+		if len(query.After) > 0 && compareUserTo(user, query.After, orders) <= 0 {
+			matched = false
+		}
+
+		if matched {
+			users = append(users, user)
+		}
+	}
+
+	return users
+}
+
+// compare two users in the given order,
+// negative when a comes before b
+func compareUsers(a domain.User, b domain.User, orders []domain.SortField) int {
+	var values []string
+	for _, order := range orders {
+		values = append(values, userField(b, order.Field))
+	}
+
+	return compareUserTo(a, values, orders)
+}
+
+// compare a user to the values of the sort fields of another one,
+// negative when the user comes before them
+func compareUserTo(user domain.User, values []string, orders []domain.SortField) int {
+	for i, order := range orders {
+		if i >= len(values) {
+			break
+		}
+
+		var result int
+		if order.Field == "id" {
+			id, _ := strconv.Atoi(values[i])
+			result = user.Id - id
+		} else {
+			result = strings.Compare(userField(user, order.Field), values[i])
+		}
+
+		if order.Desc {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+// value of a column of user as text
+func userField(user domain.User, field string) string {
+	switch field {
+	case "name":
+		return user.Name
+	case "occupation":
+		return user.Occupation
+	case "owner":
+		return user.Owner
+	default:
+		return strconv.Itoa(user.Id)
+	}
+}
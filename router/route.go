@@ -1,21 +1,72 @@
 package router
 
 import (
+	"net/http"
+
 	"github.com/iqbaltaufiq/latihan-restapi/controller"
 	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
 	"github.com/julienschmidt/httprouter"
 )
 
-// write down all of your routes here
-func NewRouter(controller controller.UserController) *httprouter.Router {
+// write down all of your routes here,
+// each with the scopes a caller needs to use it.
+// every route is rate limited by limiter, nil to disable it
+func NewRouter(controller controller.UserController, apiKeyController controller.APIKeyController, limiter *middleware.RateLimiter) *httprouter.Router {
 	router := httprouter.New()
 
-	router.GET("/api/users", controller.FindAll)
-	router.GET("/api/users/:userId", controller.FindById)
-	router.POST("/api/users", controller.Create)
-	router.PUT("/api/users/:userId", controller.Update)
-	router.DELETE("/api/users/:userId", controller.Delete)
+	// the rate limit is checked first,
+	// so callers without the scopes are counted too
+	route := func(method string, path string, handle httprouter.Handle, scopes ...string) {
+		pattern := method + " " + path
+		router.Handle(method, path, middleware.Route(pattern, limiter.Limit(pattern, middleware.Authorize(handle, scopes...))))
+	}
+
+	route(http.MethodGet, "/api/users", controller.FindAll, domain.ScopeUsersRead)
+	route(http.MethodGet, "/api/users/:userId", controller.FindById, domain.ScopeUsersRead)
+	route(http.MethodPost, "/api/users", controller.Create, domain.ScopeUsersWrite)
+	route(http.MethodPut, "/api/users/:userId", controller.Update, domain.ScopeUsersWrite)
+	route(http.MethodPatch, "/api/users/:userId", controller.Patch, domain.ScopeUsersWrite)
+	route(http.MethodDelete, "/api/users/:userId", controller.Delete, domain.ScopeUsersDelete)
+	route(http.MethodPost, "/api/users/:userId/restore", controller.Restore, domain.ScopeUsersDelete)
+
+	// httprouter doesn't allow "/api/users/bulk" next to "/api/users/:userId",
+	// so the bulk routes have a path of their own
+	route(http.MethodPost, "/api/bulk/users", controller.BulkCreate, domain.ScopeUsersWrite)
+	route(http.MethodPut, "/api/bulk/users", controller.BulkUpdate, domain.ScopeUsersWrite)
+	route(http.MethodDelete, "/api/bulk/users", controller.BulkDelete, domain.ScopeUsersDelete)
+
+	route(http.MethodGet, "/api/admin/keys", apiKeyController.FindAll, domain.ScopeAdmin)
+	route(http.MethodPost, "/api/admin/keys", apiKeyController.Issue, domain.ScopeAdmin)
+	route(http.MethodDelete, "/api/admin/keys/:keyId", apiKeyController.Revoke, domain.ScopeAdmin)
 
+	router.NotFound = http.HandlerFunc(notFound)
+	router.MethodNotAllowed = http.HandlerFunc(methodNotAllowed)
 	router.PanicHandler = exception.PanicHandler
 	return router
 }
+
+// routes of the probes, served without authentication
+func NewHealthRouter(controller controller.HealthController) *httprouter.Router {
+	router := httprouter.New()
+
+	router.GET("/healthz", middleware.Route("GET /healthz", controller.Live))
+	router.GET("/readyz", middleware.Route("GET /readyz", controller.Ready))
+
+	router.NotFound = http.HandlerFunc(notFound)
+	router.MethodNotAllowed = http.HandlerFunc(methodNotAllowed)
+	router.PanicHandler = exception.PanicHandler
+	return router
+}
+
+// answer requests matching no route like any other error,
+// instead of the plain text of httprouter
+func notFound(writer http.ResponseWriter, request *http.Request) {
+	exception.ErrorHandler(writer, request, exception.NewNotFoundError("route not found"))
+}
+
+// httprouter sets the Allow header before calling it
+func methodNotAllowed(writer http.ResponseWriter, request *http.Request) {
+	exception.ErrorHandler(writer, request, exception.NewMethodNotAllowedError("method "+request.Method+" not allowed, use "+writer.Header().Get("Allow")))
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+)
+
+type APIKeyService interface {
+	Issue(ctx context.Context, request web.APIKeyCreatePayload) (web.APIKeyIssuedResponse, error)
+	Revoke(ctx context.Context, keyId int) (web.APIKeyResponse, error)
+	FindAll(ctx context.Context) ([]web.APIKeyResponse, error)
+	// find who the key belongs to.
+	// fails with UnauthorizedError when the key is unknown, revoked or expired
+	Authenticate(ctx context.Context, key string) (domain.Principal, error)
+}
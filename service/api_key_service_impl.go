@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/repository"
+)
+
+// last used time is only written when it is older than this,
+// so a busy key doesn't cost a write on every request
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyServiceImpl struct {
+	APIKeyRepository repository.APIKeyRepository
+	Transactor       repository.Transactor
+	Validate         *validator.Validate
+}
+
+// create a constructor
+// that will be called in main.go
+func NewAPIKeyService(APIKeyRepository repository.APIKeyRepository, Transactor repository.Transactor, Validate *validator.Validate) APIKeyService {
+	return &APIKeyServiceImpl{
+		APIKeyRepository: APIKeyRepository,
+		Transactor:       Transactor,
+		Validate:         Validate,
+	}
+}
+
+// make a new key.
+// a key looks like <prefix>.<secret>, the prefix is stored to find
+// the key again and only a hash of the whole key is kept
+func (s *APIKeyServiceImpl) Issue(ctx context.Context, request web.APIKeyCreatePayload) (web.APIKeyIssuedResponse, error) {
+	err := validateStruct(s.Validate, request)
+	if err != nil {
+		return web.APIKeyIssuedResponse{}, err
+	}
+
+	prefix, err := randomBytes(6)
+	if err != nil {
+		return web.APIKeyIssuedResponse{}, err
+	}
+	secret, err := randomBytes(32)
+	if err != nil {
+		return web.APIKeyIssuedResponse{}, err
+	}
+
+	key := hex.EncodeToString(prefix) + "." + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey, err := s.APIKeyRepository.Save(ctx, domain.APIKey{
+		Prefix:    hex.EncodeToString(prefix),
+		Hash:      hashAPIKey(key),
+		Name:      request.Name,
+		Owner:     request.Owner,
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return web.APIKeyIssuedResponse{}, err
+	}
+
+	return web.APIKeyIssuedResponse{
+		APIKeyResponse: apiKeyResponse(apiKey),
+		Key:            key,
+	}, nil
+}
+
+// revoke a key, it can't be used anymore.
+// revoking a revoked key does nothing
+func (s *APIKeyServiceImpl) Revoke(ctx context.Context, keyId int) (response web.APIKeyResponse, err error) {
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := s.APIKeyRepository.FindById(ctx, keyId)
+		if err != nil {
+			return err
+		}
+
+		err = s.APIKeyRepository.Revoke(ctx, keyId, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		apiKey, err := s.APIKeyRepository.FindById(ctx, keyId)
+		response = apiKeyResponse(apiKey)
+		return err
+	})
+	if err != nil {
+		return web.APIKeyResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (s *APIKeyServiceImpl) FindAll(ctx context.Context) ([]web.APIKeyResponse, error) {
+	apiKeys, err := s.APIKeyRepository.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []web.APIKeyResponse{}
+	for _, apiKey := range apiKeys {
+		responses = append(responses, apiKeyResponse(apiKey))
+	}
+
+	return responses, nil
+}
+
+func (s *APIKeyServiceImpl) Authenticate(ctx context.Context, key string) (domain.Principal, error) {
+	// the same message for every failure,
+	// a caller guessing keys learns nothing
+	invalid := exception.NewUnauthorizedError("invalid api key")
+
+	prefix, _, ok := strings.Cut(key, ".")
+	if !ok {
+		return domain.Principal{}, invalid
+	}
+
+	apiKey, err := s.APIKeyRepository.FindByPrefix(ctx, prefix)
+	if errors.Is(err, exception.ErrNotFound) {
+		return domain.Principal{}, invalid
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(apiKey.Hash)) != 1 {
+		return domain.Principal{}, invalid
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt)) {
+		return domain.Principal{}, invalid
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		// not worth failing the request for
+		err := s.APIKeyRepository.Touch(ctx, apiKey.Id, now)
+		if err != nil {
+			slog.WarnContext(ctx, "touch api key", "key_id", apiKey.Id, "error", err)
+		}
+	}
+
+	return domain.Principal{
+		Subject: apiKey.Owner,
+		KeyId:   apiKey.Id,
+		Scopes:  apiKey.Scopes,
+	}, nil
+}
+
+// keys are long and random, a plain sha256 is enough,
+// no need for a slow password hash
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomBytes(n int) ([]byte, error) {
+	bytes := make([]byte, n)
+	_, err := rand.Read(bytes)
+	return bytes, err
+}
+
+func apiKeyResponse(apiKey domain.APIKey) web.APIKeyResponse {
+	return web.APIKeyResponse{
+		Id:         apiKey.Id,
+		Prefix:     apiKey.Prefix,
+		Name:       apiKey.Name,
+		Owner:      apiKey.Owner,
+		Scopes:     apiKey.Scopes,
+		ExpiresAt:  apiKey.ExpiresAt,
+		RevokedAt:  apiKey.RevokedAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
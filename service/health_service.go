@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+)
+
+type HealthService interface {
+	// whether the process is alive, it always is when it can answer
+	Live(ctx context.Context) web.HealthResponse
+	// whether requests can be served: the database answers,
+	// every migration is applied and the app isn't shutting down
+	Ready(ctx context.Context) web.HealthResponse
+	// make Ready fail from now on, so load balancers
+	// stop sending requests before the server shuts down
+	Drain()
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/migration"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+)
+
+type HealthServiceImpl struct {
+	// both nil with the memory driver, there is nothing to check then
+	DB       *sql.DB
+	Migrator *migration.Migrator
+	// how long a check may take before it fails
+	Timeout time.Duration
+
+	draining atomic.Bool
+}
+
+// create a constructor
+// that will be called in main.go
+func NewHealthService(DB *sql.DB, Migrator *migration.Migrator, Timeout time.Duration) HealthService {
+	return &HealthServiceImpl{
+		DB:       DB,
+		Migrator: Migrator,
+		Timeout:  Timeout,
+	}
+}
+
+func (s *HealthServiceImpl) Live(ctx context.Context) web.HealthResponse {
+	return web.HealthResponse{Status: web.HealthOK}
+}
+
+func (s *HealthServiceImpl) Ready(ctx context.Context) web.HealthResponse {
+	checks := map[string]func(ctx context.Context) error{
+		"shutdown": s.checkShutdown,
+	}
+	if s.DB != nil {
+		checks["database"] = s.checkDatabase
+		checks["migrations"] = s.checkMigrations
+	}
+
+	response := web.HealthResponse{Status: web.HealthOK, Checks: map[string]web.HealthCheck{}}
+	for name, check := range checks {
+		result := runCheck(ctx, check, s.Timeout)
+		if result.Status != web.HealthOK {
+			response.Status = web.HealthFail
+		}
+		response.Checks[name] = result
+	}
+
+	return response
+}
+
+func (s *HealthServiceImpl) Drain() {
+	s.draining.Store(true)
+}
+
+func (s *HealthServiceImpl) checkShutdown(ctx context.Context) error {
+	if s.draining.Load() {
+		return errors.New("shutting down")
+	}
+	return nil
+}
+
+func (s *HealthServiceImpl) checkDatabase(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// pending or edited migrations mean the schema
+// isn't the one this build expects
+func (s *HealthServiceImpl) checkMigrations(ctx context.Context) error {
+	statuses, err := s.Migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("migration %d %s is pending", status.Version, status.Name)
+		}
+		if status.Changed {
+			return fmt.Errorf("migration %d %s was changed after it was applied", status.Version, status.Name)
+		}
+	}
+
+	return nil
+}
+
+// run check within timeout and time it
+func runCheck(ctx context.Context, check func(ctx context.Context) error, timeout time.Duration) web.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := web.HealthCheck{Status: web.HealthOK, Latency: time.Since(start).String()}
+	if err != nil {
+		result.Status = web.HealthFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"context"
+
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+type TokenService interface {
+	// verify a bearer token and find who it was issued to.
+	// fails with UnauthorizedError when the token isn't valid
+	Authenticate(ctx context.Context, token string) (domain.Principal, error)
+}
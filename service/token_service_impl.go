@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+)
+
+// TokenKey verifies the signature of tokens
+type TokenKey struct {
+	// kid of the key, empty to match any token
+	Id string
+	// HS256, RS256 or EdDSA, a token signed
+	// with another algorithm never reaches the key
+	Algorithm string
+	// []byte, *rsa.PublicKey or ed25519.PublicKey
+	Key interface{}
+}
+
+// TokenServiceImpl accepts JWTs signed by one of Keys
+type TokenServiceImpl struct {
+	Keys []TokenKey
+	// iss and aud the tokens must have, not checked when empty
+	Issuer   string
+	Audience string
+	// clock difference allowed when checking exp and nbf
+	Leeway time.Duration
+}
+
+// create a constructor
+// that will be called in main.go
+func NewTokenService(Keys []TokenKey, Issuer string, Audience string, Leeway time.Duration) TokenService {
+	return &TokenServiceImpl{
+		Keys:     Keys,
+		Issuer:   Issuer,
+		Audience: Audience,
+		Leeway:   Leeway,
+	}
+}
+
+func (s *TokenServiceImpl) Authenticate(ctx context.Context, token string) (domain.Principal, error) {
+	var algorithms []string
+	for _, key := range s.Keys {
+		algorithms = append(algorithms, key.Algorithm)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(s.Leeway),
+	}
+	if s.Issuer != "" {
+		options = append(options, jwt.WithIssuer(s.Issuer))
+	}
+	if s.Audience != "" {
+		options = append(options, jwt.WithAudience(s.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, s.keysFor, options...)
+	if err != nil {
+		return domain.Principal{}, exception.NewUnauthorizedError("invalid bearer token: " + err.Error())
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return domain.Principal{}, exception.NewUnauthorizedError("invalid bearer token: sub is required")
+	}
+
+	return domain.Principal{
+		Subject: subject,
+		Scopes:  tokenScopes(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// the keys a token may be signed with,
+// picked by its algorithm and kid
+func (s *TokenServiceImpl) keysFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	keys := jwt.VerificationKeySet{}
+	for _, key := range s.Keys {
+		if key.Algorithm != token.Method.Alg() {
+			continue
+		}
+		if kid != "" && key.Id != "" && key.Id != kid {
+			continue
+		}
+
+		keys.Keys = append(keys.Keys, key.Key)
+	}
+
+	if len(keys.Keys) == 0 {
+		return nil, errors.New("no key to verify the token")
+	}
+	return keys, nil
+}
+
+// scopes are either a space separated "scope" claim
+// or a "scp" array, depending on who issued the token,
+// plus the scopes of the roles in a "roles" array
+func tokenScopes(claims jwt.MapClaims) []string {
+	var scopes []string
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = strings.Fields(scope)
+	} else {
+		scopes = claimStrings(claims, "scp")
+	}
+
+	return append(scopes, domain.RoleScopes(claimStrings(claims, "roles"))...)
+}
+
+// the strings of an array claim
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	var values []string
+	if array, ok := claims[name].([]interface{}); ok {
+		for _, value := range array {
+			if value, ok := value.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
@@ -2,14 +2,21 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/iqbaltaufiq/latihan-restapi/model/web"
 )
 
 type UserService interface {
-	Create(ctx context.Context, request web.UserCreatePayload) web.UserResponse
-	Update(ctx context.Context, request web.UserUpdatePayload) web.UserResponse
-	Delete(ctx context.Context, userId int)
-	FindById(ctx context.Context, userId int) web.UserResponse
-	FindAll(ctx context.Context) []web.UserResponse
+	Create(ctx context.Context, request web.UserCreatePayload) (web.UserResponse, error)
+	Update(ctx context.Context, request web.UserUpdatePayload) (web.UserResponse, error)
+	Patch(ctx context.Context, request web.UserPatchPayload) (web.UserResponse, error)
+	Delete(ctx context.Context, userId int, versions []int) error
+	Restore(ctx context.Context, userId int, versions []int) (web.UserResponse, error)
+	Purge(ctx context.Context, retention time.Duration) (int, error)
+	FindById(ctx context.Context, userId int, includeDeleted bool) (web.UserResponse, error)
+	FindAll(ctx context.Context, request web.UserFindAllPayload) (web.UserListResponse, error)
+	BulkCreate(ctx context.Context, request web.UserBulkCreatePayload) ([]web.BulkItemResponse, error)
+	BulkUpdate(ctx context.Context, request web.UserBulkUpdatePayload) ([]web.BulkItemResponse, error)
+	BulkDelete(ctx context.Context, request web.UserBulkDeletePayload) ([]web.BulkItemResponse, error)
 }
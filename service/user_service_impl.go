@@ -1,9 +1,17 @@
 package service
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/iqbaltaufiq/latihan-restapi/exception"
 	"github.com/iqbaltaufiq/latihan-restapi/helper"
@@ -14,117 +22,750 @@ import (
 
 type UserServiceImpl struct {
 	UserRepository repository.UserRepository
-	DB             *sql.DB
+	Transactor     repository.Transactor
 	Validate       *validator.Validate
+	// key used to sign and verify pagination cursors
+	CursorSecret []byte
 }
 
 // create a constructor
 // that will be called in main.go
-func NewUserService(UserRepository repository.UserRepository, DB *sql.DB, Validate *validator.Validate) UserService {
+func NewUserService(UserRepository repository.UserRepository, Transactor repository.Transactor, Validate *validator.Validate, CursorSecret []byte) UserService {
 	return &UserServiceImpl{
 		UserRepository: UserRepository,
-		DB:             DB,
+		Transactor:     Transactor,
 		Validate:       Validate,
+		CursorSecret:   CursorSecret,
 	}
 }
 
-func (s *UserServiceImpl) Create(ctx context.Context, request web.UserCreatePayload) web.UserResponse {
-	// do validation for the payload struct
-	err := s.Validate.Struct(request)
-	helper.PanicIfError(err)
+func (s *UserServiceImpl) Create(ctx context.Context, request web.UserCreatePayload) (response web.UserResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.Create")
+	defer func() { helper.EndSpan(span, err) }()
 
-	// start a db transaction
-	tx, err := s.DB.Begin()
-	helper.PanicIfError(err)
-	defer helper.CommitOrRollback(tx)
+	// do validation for the payload struct
+	err = s.validate(request)
+	if err != nil {
+		return response, err
+	}
 
 	// payload mapping before being sent to repository
 	payload := domain.User{
 		Name:       request.Name,
 		Occupation: request.Occupation,
+		Owner:      ownerOf(ctx),
 	}
 
 	// send payload to repository
 	// to be inserted into DB
-	user := s.UserRepository.Save(ctx, tx, payload)
-
-	return web.UserResponse{
-		Id:         user.Id,
-		Name:       user.Name,
-		Occupation: user.Occupation,
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.UserRepository.Save(ctx, payload)
+		response = userResponse(user)
+		return err
+	})
+	if err != nil {
+		return web.UserResponse{}, err
 	}
+
+	return response, nil
 }
 
-func (s *UserServiceImpl) Update(ctx context.Context, request web.UserUpdatePayload) web.UserResponse {
+func (s *UserServiceImpl) Update(ctx context.Context, request web.UserUpdatePayload) (response web.UserResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.Update")
+	defer func() { helper.EndSpan(span, err) }()
+
 	// do validation for the payload struct
-	err := s.Validate.Struct(request)
-	helper.PanicIfError(err)
+	err = s.validate(request)
+	if err != nil {
+		return response, err
+	}
 
-	// make a db transaction
-	tx, err := s.DB.Begin()
-	helper.PanicIfError(err)
-	defer helper.CommitOrRollback(tx)
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// find the user in DB
+		userInDB, err := s.UserRepository.FindById(ctx, request.Id, ownerScope(ctx), false)
+		if err != nil {
+			return err
+		}
 
-	// find the user in DB
-	userInDB, err := s.UserRepository.FindById(ctx, tx, request.Id)
+		versions := request.IfMatch
+		if len(versions) == 0 {
+			versions = []int{request.Version}
+		}
+		err = checkVersion(userInDB, versions...)
+		if err != nil {
+			return err
+		}
+
+		// PUT replaces every field
+		userInDB.Name = request.Name
+		userInDB.Occupation = request.Occupation
+
+		user, err := s.UserRepository.Update(ctx, userInDB)
+		response = userResponse(user)
+		return err
+	})
 	if err != nil {
-		panic(exception.NewNotFoundError(err.Error()))
+		return web.UserResponse{}, err
 	}
 
-	userInDB.Name = request.Name
+	return response, nil
+}
 
-	user := s.UserRepository.Update(ctx, tx, userInDB)
-	return web.UserResponse{
-		Id:         user.Id,
-		Name:       user.Name,
-		Occupation: user.Occupation,
+func (s *UserServiceImpl) Patch(ctx context.Context, request web.UserPatchPayload) (response web.UserResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.Patch")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		userInDB, err := s.UserRepository.FindById(ctx, request.Id, ownerScope(ctx), false)
+		if err != nil {
+			return err
+		}
+
+		err = checkVersion(userInDB, request.Versions...)
+		if err != nil {
+			return err
+		}
+
+		// the patch is applied to the same document PUT accepts,
+		// so the result can be validated the same way
+		document, err := json.Marshal(web.UserUpdatePayload{
+			Id:         userInDB.Id,
+			Name:       userInDB.Name,
+			Occupation: userInDB.Occupation,
+		})
+		if err != nil {
+			return err
+		}
+
+		patched, err := applyPatch(request.Type, request.Patch, document)
+		if err != nil {
+			return err
+		}
+
+		// as strict as the request bodies,
+		// a patch adding a field the user doesn't have is refused
+		payload := web.UserUpdatePayload{}
+		decoder := json.NewDecoder(bytes.NewReader(patched))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&payload)
+		if err != nil {
+			return exception.NewValidationError("patched user is not a valid user: " + strings.TrimPrefix(err.Error(), "json: "))
+		}
+
+		if payload.Id != userInDB.Id {
+			return exception.NewValidationError("id can't be changed")
+		}
+
+		err = s.validate(payload)
+		if err != nil {
+			return err
+		}
+
+		// nothing changed, the version and so the etag stay the same
+		if payload.Name == userInDB.Name && payload.Occupation == userInDB.Occupation {
+			response = userResponse(userInDB)
+			return nil
+		}
+
+		userInDB.Name = payload.Name
+		userInDB.Occupation = payload.Occupation
+
+		user, err := s.UserRepository.Update(ctx, userInDB)
+		response = userResponse(user)
+		return err
+	})
+	if err != nil {
+		return web.UserResponse{}, err
 	}
+
+	return response, nil
 }
 
-func (s *UserServiceImpl) Delete(ctx context.Context, userId int) {
-	tx, err := s.DB.Begin()
-	helper.PanicIfError(err)
-	defer helper.CommitOrRollback(tx)
+func (s *UserServiceImpl) Delete(ctx context.Context, userId int, versions []int) (err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.Delete")
+	defer func() { helper.EndSpan(span, err) }()
+
+	return s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.UserRepository.FindById(ctx, userId, ownerScope(ctx), false)
+		if err != nil {
+			return err
+		}
 
-	user, err := s.UserRepository.FindById(ctx, tx, userId)
+		err = checkVersion(user, versions...)
+		if err != nil {
+			return err
+		}
+
+		return s.UserRepository.Delete(ctx, user)
+	})
+}
+
+func (s *UserServiceImpl) Restore(ctx context.Context, userId int, versions []int) (response web.UserResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.Restore")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.UserRepository.FindById(ctx, userId, ownerScope(ctx), true)
+		if err != nil {
+			return err
+		}
+
+		if user.DeletedAt == nil {
+			return exception.NewConflictError("user is not deleted")
+		}
+
+		err = checkVersion(user, versions...)
+		if err != nil {
+			return err
+		}
+
+		user, err = s.UserRepository.Restore(ctx, user)
+		response = userResponse(user)
+		return err
+	})
 	if err != nil {
-		panic(exception.NewNotFoundError(err.Error()))
+		return web.UserResponse{}, err
 	}
 
-	s.UserRepository.Delete(ctx, tx, user.Id)
+	return response, nil
 }
 
-func (s *UserServiceImpl) FindById(ctx context.Context, userId int) web.UserResponse {
-	tx, err := s.DB.Begin()
-	helper.PanicIfError(err)
-	defer helper.CommitOrRollback(tx)
+// hard delete users that have been soft deleted for longer than retention.
+// returns the number of users removed
+func (s *UserServiceImpl) Purge(ctx context.Context, retention time.Duration) (purged int, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.Purge")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		purged, err = s.UserRepository.Purge(ctx, time.Now().Add(-retention))
+		return err
+	})
+
+	return purged, err
+}
+
+func (s *UserServiceImpl) FindById(ctx context.Context, userId int, includeDeleted bool) (response web.UserResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.FindById")
+	defer func() { helper.EndSpan(span, err) }()
+
+	user, err := s.UserRepository.FindById(ctx, userId, ownerScope(ctx), includeDeleted)
+	if err != nil {
+		return response, err
+	}
+
+	return userResponse(user), nil
+}
+
+func (s *UserServiceImpl) FindAll(ctx context.Context, request web.UserFindAllPayload) (response web.UserListResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.FindAll")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.validate(request)
+	if err != nil {
+		return response, err
+	}
+
+	// after and limit switch listing into cursor mode
+	if request.After != "" || request.Limit != 0 {
+		return s.findAllAfter(ctx, request)
+	}
+
+	// page and size are optional in the query string
+	if request.Page == 0 {
+		request.Page = 1
+	}
+	if request.Size == 0 {
+		request.Size = 10
+	}
+
+	query := domain.UserQuery{
+		Filters:        request.Filters,
+		Sort:           userSortFields(request.Sort),
+		Limit:          request.Size,
+		Offset:         (request.Page - 1) * request.Size,
+		IncludeDeleted: request.IncludeDeleted,
+		Owner:          ownerScope(ctx),
+	}
+
+	// users and total are read in the same transaction
+	// so they agree with each other
+	var users []domain.User
+	var total int
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		users, err = s.UserRepository.FindAll(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		total, err = s.UserRepository.Count(ctx, query)
+		return err
+	})
+	if err != nil {
+		return response, err
+	}
+
+	return web.UserListResponse{
+		Users: userResponses(users),
+		Meta: web.PageMeta{
+			Page:       request.Page,
+			Size:       request.Size,
+			TotalItems: total,
+			TotalPages: (total + request.Size - 1) / request.Size,
+		},
+	}, nil
+}
+
+// list users placed after the cursor.
+// unlike paging with offset, the database doesn't have to
+// skip all of the previous rows, so it stays fast on big tables.
+func (s *UserServiceImpl) findAllAfter(ctx context.Context, request web.UserFindAllPayload) (response web.UserListResponse, err error) {
+	if request.Limit == 0 {
+		request.Limit = 10
+	}
+
+	// id is always the last sort field,
+	// it makes the sort key of every row unique
+	sort := request.Sort
+	sortedById := false
+	for _, field := range sort {
+		if field == "id" || field == "-id" {
+			sortedById = true
+		}
+	}
+	if !sortedById {
+		sort = append(sort, "id")
+	}
+
+	query := domain.UserQuery{
+		Filters: request.Filters,
+		Sort:    userSortFields(sort),
+		// fetch one more row to know if there is a next page
+		Limit:          request.Limit + 1,
+		IncludeDeleted: request.IncludeDeleted,
+		Owner:          ownerScope(ctx),
+	}
+
+	if request.After != "" {
+		cursor, err := helper.DecodeCursor(request.After, s.CursorSecret)
+		if err != nil {
+			return response, exception.NewValidationError(err.Error())
+		}
+
+		// a cursor only makes sense with the query it was made with,
+		// other filters would skip or repeat rows
+		if len(request.Sort) > 0 && strings.Join(cursor.Sort, ",") != strings.Join(sort, ",") {
+			return response, exception.NewValidationError("sort doesn't match the cursor")
+		}
+		if cursor.Query != cursorQuery(query) {
+			return response, exception.NewValidationError("filters don't match the cursor")
+		}
+		if len(cursor.Values) != len(cursor.Sort) {
+			return response, exception.NewValidationError("malformed cursor")
+		}
+
+		sort = cursor.Sort
+		query.Sort = userSortFields(sort)
+		query.After = cursor.Values
+	}
+
+	users, err := s.UserRepository.FindAll(ctx, query)
+	if err != nil {
+		return response, err
+	}
+
+	meta := web.CursorMeta{Limit: request.Limit}
+	if len(users) > request.Limit {
+		users = users[:request.Limit]
+
+		last := users[len(users)-1]
+		cursor := helper.Cursor{Sort: sort, Query: cursorQuery(query)}
+		for _, field := range query.Sort {
+			cursor.Values = append(cursor.Values, userSortValue(last, field.Field))
+		}
+
+		nextCursor := helper.EncodeCursor(cursor, s.CursorSecret)
+		meta.NextCursor = &nextCursor
+	}
+
+	return web.UserListResponse{
+		Users: userResponses(users),
+		Meta:  meta,
+	}, nil
+}
+
+// create many users in one transaction.
+// every item is validated on its own and reported in the response.
+// in atomic mode nothing is saved if any item fails
+func (s *UserServiceImpl) BulkCreate(ctx context.Context, request web.UserBulkCreatePayload) (responses []web.BulkItemResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.BulkCreate")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.validate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responses = make([]web.BulkItemResponse, len(request.Users))
+
+	// position in the request of every user to be saved
+	var indexes []int
+	var users []domain.User
+	for i, payload := range request.Users {
+		responses[i].Index = i
+		responses[i].Err = s.validateItem(payload, i)
+		if responses[i].Err != nil {
+			continue
+		}
+
+		indexes = append(indexes, i)
+		users = append(users, domain.User{
+			Name:       payload.Name,
+			Occupation: payload.Occupation,
+			Owner:      ownerOf(ctx),
+		})
+	}
+
+	if bulkFailed(request.Mode, responses) || len(users) == 0 {
+		return responses, nil
+	}
 
-	user, err := s.UserRepository.FindById(ctx, tx, userId)
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		users, err = s.UserRepository.SaveAll(ctx, users)
+		return err
+	})
 	if err != nil {
-		panic(exception.NewNotFoundError(err.Error()))
+		return nil, err
 	}
 
+	for i, user := range users {
+		responses[indexes[i]].Data = userResponse(user)
+	}
+
+	return responses, nil
+}
+
+// replace many users in one transaction.
+// an item fails if it's invalid, not found or not in the expected version.
+// in atomic mode nothing is saved if any item fails
+func (s *UserServiceImpl) BulkUpdate(ctx context.Context, request web.UserBulkUpdatePayload) (responses []web.BulkItemResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.BulkUpdate")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.validate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		responses, err = s.bulkUpdate(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// BulkUpdate inside its transaction
+func (s *UserServiceImpl) bulkUpdate(ctx context.Context, request web.UserBulkUpdatePayload) ([]web.BulkItemResponse, error) {
+	responses := make([]web.BulkItemResponse, len(request.Users))
+
+	var indexes []int
+	var users []domain.User
+	seen := map[int]bool{}
+	for i, payload := range request.Users {
+		responses[i].Index = i
+
+		responses[i].Err = s.validateItem(payload, i)
+		if responses[i].Err != nil {
+			continue
+		}
+
+		var user domain.User
+		user, responses[i].Err = s.findForBulk(ctx, payload.Id, payload.Version, seen)
+		if errors.Is(responses[i].Err, exception.ErrInternal) {
+			return nil, responses[i].Err
+		}
+		if responses[i].Err != nil {
+			continue
+		}
+
+		user.Name = payload.Name
+		user.Occupation = payload.Occupation
+
+		indexes = append(indexes, i)
+		users = append(users, user)
+	}
+
+	if bulkFailed(request.Mode, responses) {
+		return responses, nil
+	}
+
+	for i, user := range users {
+		user, err := s.UserRepository.Update(ctx, user)
+
+		// a concurrent update slipped in between reading and writing.
+		// in atomic mode the whole request fails and is rolled back
+		if errors.Is(err, exception.ErrPreconditionFailed) && request.Mode == web.BulkBestEffort {
+			responses[indexes[i]].Err = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		responses[indexes[i]].Data = userResponse(user)
+	}
+
+	return responses, nil
+}
+
+// soft delete many users in one transaction.
+// an item fails if the user is not found.
+// in atomic mode nothing is deleted if any item fails
+func (s *UserServiceImpl) BulkDelete(ctx context.Context, request web.UserBulkDeletePayload) (responses []web.BulkItemResponse, err error) {
+	ctx, span := helper.StartSpan(ctx, "UserService.BulkDelete")
+	defer func() { helper.EndSpan(span, err) }()
+
+	err = s.validate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		responses, err = s.bulkDelete(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// BulkDelete inside its transaction
+func (s *UserServiceImpl) bulkDelete(ctx context.Context, request web.UserBulkDeletePayload) ([]web.BulkItemResponse, error) {
+	responses := make([]web.BulkItemResponse, len(request.Ids))
+
+	var userIds []int
+	seen := map[int]bool{}
+	for i, userId := range request.Ids {
+		responses[i].Index = i
+
+		_, responses[i].Err = s.findForBulk(ctx, userId, 0, seen)
+		if errors.Is(responses[i].Err, exception.ErrInternal) {
+			return nil, responses[i].Err
+		}
+		if responses[i].Err == nil {
+			userIds = append(userIds, userId)
+		}
+	}
+
+	if bulkFailed(request.Mode, responses) {
+		return responses, nil
+	}
+
+	err := s.UserRepository.DeleteAll(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// find the user an item of a bulk request points to.
+// the error is about the item itself, unless it is an InternalError
+func (s *UserServiceImpl) findForBulk(ctx context.Context, userId int, version int, seen map[int]bool) (domain.User, error) {
+	// the second change to the same user would always fail
+	// against the version bumped by the first one
+	if seen[userId] {
+		return domain.User{}, exception.NewValidationError("user " + strconv.Itoa(userId) + " appears more than once")
+	}
+	seen[userId] = true
+
+	user, err := s.UserRepository.FindById(ctx, userId, ownerScope(ctx), false)
+	if errors.Is(err, exception.ErrNotFound) {
+		return user, err
+	}
+	if err != nil {
+		return user, exception.NewInternalError(err)
+	}
+
+	return user, checkVersion(user, version)
+}
+
+// in atomic mode a single failed item fails the whole request
+func bulkFailed(mode string, responses []web.BulkItemResponse) bool {
+	if mode == web.BulkBestEffort {
+		return false
+	}
+
+	for _, response := range responses {
+		if response.Err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *UserServiceImpl) validate(payload interface{}) error {
+	return validateStruct(s.Validate, payload)
+}
+
+// validate the item at index of a bulk request,
+// its fields are at "$[index]" of the body
+func (s *UserServiceImpl) validateItem(payload interface{}, index int) error {
+	err := s.validate(payload)
+
+	var validationError *exception.ValidationError
+	if errors.As(err, &validationError) {
+		validationError.Path = "$[" + strconv.Itoa(index) + "]"
+	}
+
+	return err
+}
+
+// validate the payload struct.
+// the errors are wrapped so the controller responds with 400
+func validateStruct(validate *validator.Validate, payload interface{}) error {
+	err := validate.Struct(payload)
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return exception.NewValidationErrorFrom(validationErrors, payload)
+	}
+
+	return err
+}
+
+// compare the versions the client expects with the one in database,
+// the user must be in one of them.
+// none or 0 means the client didn't ask for a version
+func checkVersion(user domain.User, versions ...int) error {
+	for _, version := range versions {
+		if version == 0 || version == user.Version {
+			return nil
+		}
+	}
+
+	if len(versions) > 0 {
+		return exception.NewPreconditionFailedError("user was modified since it was read")
+	}
+	return nil
+}
+
+// apply a merge patch or a json patch to the document.
+// a malformed patch is the client's fault, so is a patch
+// that can't be applied to the current user, e.g. a failed "test" operation
+func applyPatch(patchType string, patch []byte, document []byte) ([]byte, error) {
+	switch patchType {
+	case web.MergePatch:
+		patched, err := jsonpatch.MergePatch(document, patch)
+		if err != nil {
+			return nil, exception.NewValidationError("invalid merge patch: " + err.Error())
+		}
+		return patched, nil
+
+	case web.JSONPatch:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, exception.NewValidationError("invalid json patch: " + err.Error())
+		}
+
+		patched, err := operations.Apply(document)
+		if err != nil {
+			return nil, exception.NewConflictError("json patch can't be applied: " + err.Error())
+		}
+		return patched, nil
+
+	default:
+		return nil, exception.NewUnsupportedMediaTypeError("patch must be " + web.MergePatch + " or " + web.JSONPatch)
+	}
+}
+
+// map sort fields from the query string into a sort the repository understands.
+// "-name" means sort by name descending
+func userSortFields(sort []string) []domain.SortField {
+	var fields []domain.SortField
+	for _, field := range sort {
+		fields = append(fields, domain.SortField{
+			Field: strings.TrimPrefix(field, "-"),
+			Desc:  strings.HasPrefix(field, "-"),
+		})
+	}
+
+	return fields
+}
+
+// hash of what a cursor is bound to besides its sort order,
+// which the cursor keeps as it is.
+// json sorts the keys of the filters, so their order doesn't matter
+func cursorQuery(query domain.UserQuery) string {
+	normalized, err := json.Marshal(struct {
+		Filters        map[string]string
+		IncludeDeleted bool
+		Owner          string
+	}{query.Filters, query.IncludeDeleted, query.Owner})
+	helper.PanicIfError(err)
+
+	hash := sha256.Sum256(normalized)
+	return base64.RawURLEncoding.EncodeToString(hash[:16])
+}
+
+// value of a sortable field, to be stored in a cursor
+func userSortValue(user domain.User, field string) string {
+	switch field {
+	case "name":
+		return user.Name
+	case "occupation":
+		return user.Occupation
+	case "owner":
+		return user.Owner
+	default:
+		return strconv.Itoa(user.Id)
+	}
+}
+
+func userResponse(user domain.User) web.UserResponse {
 	return web.UserResponse{
 		Id:         user.Id,
 		Name:       user.Name,
 		Occupation: user.Occupation,
+		Owner:      user.Owner,
+		Version:    user.Version,
+		DeletedAt:  user.DeletedAt,
 	}
 }
 
-func (s *UserServiceImpl) FindAll(ctx context.Context) []web.UserResponse {
-	tx, err := s.DB.Begin()
-	helper.PanicIfError(err)
-	defer helper.CommitOrRollback(tx)
+// subject of the caller, stored as the owner of the users it creates.
+// empty for internal callers like the purge job
+func ownerOf(ctx context.Context) string {
+	principal, ok := helper.PrincipalFrom(ctx)
+	if !ok {
+		return ""
+	}
 
-	users := s.UserRepository.FindAll(ctx, tx)
+	return principal.Subject
+}
+
+// owner the caller is limited to. admins and internal
+// callers see every user, the others only their own.
+// users of someone else are reported as not found
+func ownerScope(ctx context.Context) string {
+	principal, ok := helper.PrincipalFrom(ctx)
+	if !ok || principal.HasScope(domain.ScopeAdmin) {
+		return ""
+	}
 
-	var responses []web.UserResponse
+	return principal.Subject
+}
+
+func userResponses(users []domain.User) []web.UserResponse {
+	responses := []web.UserResponse{}
 	for _, user := range users {
-		responses = append(responses, web.UserResponse{
-			Id:         user.Id,
-			Name:       user.Name,
-			Occupation: user.Occupation,
-		})
+		responses = append(responses, userResponse(user))
 	}
 
 	return responses
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the api key endpoints
+// and the keys they issue.
+
+func TestAPIKeys(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	send := func(method string, url string, apiKey string, payload string) (*http.Response, map[string]interface{}) {
+		return sendRequest(router, method, url, payload, "X-API-KEY", apiKey)
+	}
+
+	// the key from the config issues the first key
+	response, responseBody := send(http.MethodPost, "/api/admin/keys", "SECRET", `{"name": "import job", "owner": "john", "scopes": ["users:read"]}`)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "no-store", response.Header.Get("Cache-Control"))
+
+	issued := responseBody["data"].(map[string]interface{})
+	key := issued["key"].(string)
+	keyId := int(issued["id"].(float64))
+	assert.True(t, strings.HasPrefix(key, issued["prefix"].(string)+"."))
+	assert.Equal(t, []interface{}{"users:read"}, issued["scopes"])
+
+	response, _ = send(http.MethodGet, "/api/users", key, "")
+	assert.Equal(t, 200, response.StatusCode)
+
+	// only admins manage keys
+	response, responseBody = send(http.MethodGet, "/api/admin/keys", key, "")
+	assert.Equal(t, 403, response.StatusCode)
+	assert.Equal(t, "missing scope: admin", responseBody["data"])
+
+	// the key is never shown again, nor is its hash
+	response, responseBody = send(http.MethodGet, "/api/admin/keys", "SECRET", "")
+	assert.Equal(t, 200, response.StatusCode)
+	listed := responseBody["data"].([]interface{})[0].(map[string]interface{})
+	assert.Nil(t, listed["key"])
+	assert.Nil(t, listed["hash"])
+	assert.NotNil(t, listed["last_used_at"])
+
+	var hash string
+	db.QueryRow("SELECT hash FROM api_keys WHERE id = ?", keyId).Scan(&hash)
+	assert.NotContains(t, hash, key)
+	assert.Len(t, hash, 64)
+
+	// right prefix, wrong secret
+	response, _ = send(http.MethodGet, "/api/users", issued["prefix"].(string)+".guess", "")
+	assert.Equal(t, 401, response.StatusCode)
+
+	response, _ = send(http.MethodPost, "/api/admin/keys", "SECRET", `{"name": "bad", "owner": "john", "scopes": ["users:everything"]}`)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response, _ = send(http.MethodPost, "/api/admin/keys", "SECRET", `{"name": "bad", "owner": "john", "scopes": ["users:read"], "expires_at": "2001-01-01T00:00:00Z"}`)
+	assert.Equal(t, 400, response.StatusCode)
+
+	// an expired key is refused
+	_, responseBody = send(http.MethodPost, "/api/admin/keys", "SECRET", `{"name": "temporary", "owner": "anne", "scopes": ["users:read"], "expires_at": "`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`)
+	temporary := responseBody["data"].(map[string]interface{})
+
+	response, _ = send(http.MethodGet, "/api/users", temporary["key"].(string), "")
+	assert.Equal(t, 200, response.StatusCode)
+
+	db.Exec("UPDATE api_keys SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), int(temporary["id"].(float64)))
+
+	response, _ = send(http.MethodGet, "/api/users", temporary["key"].(string), "")
+	assert.Equal(t, 401, response.StatusCode)
+
+	// so is a revoked one
+	response, responseBody = send(http.MethodDelete, fmt.Sprintf("/api/admin/keys/%d", keyId), "SECRET", "")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotNil(t, responseBody["data"].(map[string]interface{})["revoked_at"])
+
+	response, _ = send(http.MethodGet, "/api/users", key, "")
+	assert.Equal(t, 401, response.StatusCode)
+
+	response, _ = send(http.MethodDelete, "/api/admin/keys/1000", "SECRET", "")
+	assert.Equal(t, 404, response.StatusCode)
+}
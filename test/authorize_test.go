@@ -0,0 +1,78 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the scopes every route requires.
+
+func TestRouteScopes(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	router := setupTokenRouter(t, config.JWTConfig{Secret: string(secret)})
+
+	token := func(claims jwt.MapClaims) string {
+		claims["sub"] = "john"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		return signToken(t, jwt.SigningMethodHS256, "", secret, claims)
+	}
+
+	send := func(method string, url string, token string, payload string) (int, string) {
+		response, responseBody := sendRequest(router, method, url, payload, "Authorization", "Bearer "+token)
+		message, _ := responseBody["data"].(string)
+		return response.StatusCode, message
+	}
+
+	writer := token(jwt.MapClaims{"scope": "users:read users:write"})
+	status, _ := send(http.MethodPost, "/api/users", writer, `{"name": "John", "occupation": "student"}`)
+	assert.Equal(t, 200, status)
+
+	reader := token(jwt.MapClaims{"scope": "users:read"})
+	requests := []struct {
+		method  string
+		url     string
+		status  int
+		message string
+	}{
+		{http.MethodGet, "/api/users", 200, ""},
+		{http.MethodGet, "/api/users/1", 200, ""},
+		{http.MethodPost, "/api/users", 403, "missing scope: users:write"},
+		{http.MethodPut, "/api/users/1", 403, "missing scope: users:write"},
+		{http.MethodPatch, "/api/users/1", 403, "missing scope: users:write"},
+		{http.MethodPost, "/api/bulk/users", 403, "missing scope: users:write"},
+		{http.MethodDelete, "/api/users/1", 403, "missing scope: users:delete"},
+		{http.MethodDelete, "/api/bulk/users", 403, "missing scope: users:delete"},
+		{http.MethodPost, "/api/users/1/restore", 403, "missing scope: users:delete"},
+		{http.MethodGet, "/api/admin/keys", 403, "missing scope: admin"},
+		{http.MethodGet, "/api/users?include_deleted=true", 403, "missing scope: admin"},
+		{http.MethodGet, "/api/users/1?include_deleted=true", 403, "missing scope: admin"},
+	}
+	for _, request := range requests {
+		status, message := send(request.method, request.url, reader, `{}`)
+		assert.Equal(t, request.status, status, request.method+" "+request.url)
+		if request.message != "" {
+			assert.Equal(t, request.message, message, request.method+" "+request.url)
+		}
+	}
+
+	// roles grant their scopes
+	editor := token(jwt.MapClaims{"roles": []string{"editor"}})
+	status, _ = send(http.MethodPut, "/api/users/1", editor, `{"name": "Jack", "occupation": "teacher"}`)
+	assert.Equal(t, 200, status)
+
+	status, _ = send(http.MethodDelete, "/api/users/1", editor, "")
+	assert.Equal(t, 403, status)
+
+	owner := token(jwt.MapClaims{"roles": []string{"owner"}})
+	status, _ = send(http.MethodDelete, "/api/users/1", owner, "")
+	assert.Equal(t, 200, status)
+
+	admin := token(jwt.MapClaims{"roles": []string{"admin"}})
+	status, _ = send(http.MethodGet, "/api/users/1?include_deleted=true", admin, "")
+	assert.Equal(t, 200, status)
+}
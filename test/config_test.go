@@ -0,0 +1,76 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is a unit testing for the config package.
+// To make sure that the file and the environment
+// are read in the right order and validated.
+
+func TestLoadConfigFromFileAndEnv(t *testing.T) {
+	// write a config file to be overridden by env
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("server:\n  addr: localhost:4000\ndatabase:\n  max_open_conns: 30\n  conn_max_lifetime: 5m\nauth:\n  api_key: from-file\n"), 0600)
+
+	t.Setenv("APP_AUTH_API_KEY", "from-env")
+
+	cfg, err := config.Load(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost:4000", cfg.Server.Addr)
+	assert.Equal(t, 30, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 5*time.Minute, cfg.Database.ConnMaxLifetime)
+	assert.Equal(t, 10*time.Minute, cfg.Database.ConnMaxIdleTime)
+	assert.Equal(t, "from-env", cfg.Auth.APIKey)
+	assert.NotEmpty(t, cfg.Auth.CursorSecret)
+}
+
+func TestLoadConfigFromToml(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[database]\nconn_max_idle_time = \"30s\"\n\n[auth]\napi_key = \"SECRET\"\n"), 0600)
+
+	cfg, err := config.Load(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Database.ConnMaxIdleTime)
+	assert.Equal(t, "SECRET", cfg.Auth.APIKey)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	// driver must be one we support
+	t.Setenv("APP_DATABASE_DRIVER", "oracle")
+	_, err := config.Load("")
+	assert.NotNil(t, err)
+
+	// idle connections can't exceed open connections
+	t.Setenv("APP_DATABASE_DRIVER", "mysql")
+	t.Setenv("APP_DATABASE_MAX_IDLE_CONNS", "50")
+	_, err = config.Load("")
+	assert.NotNil(t, err)
+
+	t.Setenv("APP_DATABASE_MAX_IDLE_CONNS", "five")
+	_, err = config.Load("")
+	assert.NotNil(t, err)
+}
+
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.APIKey = "SECRET"
+
+	var output bytes.Buffer
+	err := config.Print(&output, cfg)
+
+	assert.Nil(t, err)
+	assert.Contains(t, output.String(), "addr: localhost:3000")
+	assert.Contains(t, output.String(), "api_key: '[REDACTED]'")
+	assert.NotContains(t, output.String(), "SECRET")
+	assert.NotContains(t, output.String(), "root:@tcp")
+}
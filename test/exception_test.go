@@ -0,0 +1,49 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is a unit testing for the error hierarchy.
+// To make sure that errors keep their kind when wrapped.
+
+func TestErrorStatusCode(t *testing.T) {
+	errs := map[error]int{
+		exception.NewValidationError("name is required"):  http.StatusBadRequest,
+		exception.NewUnauthorizedError("invalid api key"): http.StatusUnauthorized,
+		exception.NewForbiddenError("admin only"):         http.StatusForbidden,
+		exception.NewNotFoundError("user not found"):      http.StatusNotFound,
+		exception.NewConflictError("user already exists"): http.StatusConflict,
+		exception.NewTooManyRequestsError("slow down"):    http.StatusTooManyRequests,
+		exception.NewInternalError(errors.New("boom")):    http.StatusInternalServerError,
+		errors.New("unknown"):                             http.StatusInternalServerError,
+	}
+
+	for err, code := range errs {
+		// wrapping must not change the status code
+		wrapped := fmt.Errorf("find user: %w", err)
+
+		assert.Equal(t, code, exception.StatusCode(err), err.Error())
+		assert.Equal(t, code, exception.StatusCode(wrapped), err.Error())
+	}
+}
+
+func TestErrorIsAndAs(t *testing.T) {
+	err := fmt.Errorf("find user: %w", exception.NewNotFoundError("user not found"))
+
+	var notFound *exception.NotFoundError
+	assert.True(t, errors.Is(err, exception.ErrNotFound))
+	assert.False(t, errors.Is(err, exception.ErrConflict))
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "user not found", notFound.Message)
+
+	// the cause of an internal error is still reachable
+	cause := errors.New("connection refused")
+	assert.True(t, errors.Is(exception.NewInternalError(cause), cause))
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the probes,
+// mounted next to the api like in main.go.
+
+func setupHealthRouter(t *testing.T, healthService service.HealthService) http.Handler {
+	// the api itself isn't needed, only the auth in front of it
+	api := middleware.NewAuthMiddleware(http.NotFoundHandler(), nil, nil, "SECRET")
+	healthRouter := router.NewHealthRouter(controller.NewHealthController(healthService))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", api)
+	mux.Handle("/healthz", healthRouter)
+	mux.Handle("/readyz", healthRouter)
+	return mux
+}
+
+// status code and the health in the body, no api key is sent
+func probe(handler http.Handler, url string) (int, web.HealthResponse) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000"+url, nil)
+
+	handler.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	body, _ := io.ReadAll(response.Body)
+	var responseBody struct {
+		Data web.HealthResponse
+	}
+	json.Unmarshal(body, &responseBody)
+	return response.StatusCode, responseBody.Data
+}
+
+func TestHealth(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Driver = "sqlite"
+	cfg.DSN = "file:" + filepath.Join(t.TempDir(), "users.db") + "?_pragma=busy_timeout(5000)"
+
+	storage, err := app.NewStorage(cfg)
+	assert.Nil(t, err)
+
+	healthService, err := app.NewHealthService(storage.DB, cfg, config.HealthConfig{Timeout: time.Second})
+	assert.Nil(t, err)
+	handler := setupHealthRouter(t, healthService)
+
+	status, health := probe(handler, "/healthz")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "ok", health.Status)
+
+	// not migrated yet
+	status, health = probe(handler, "/readyz")
+	assert.Equal(t, 503, status)
+	assert.Equal(t, "fail", health.Status)
+	assert.Equal(t, "ok", health.Checks["database"].Status)
+	assert.Equal(t, "migration 1 create_user_table is pending", health.Checks["migrations"].Error)
+
+	cfg.MigrateOnStart = true
+	assert.Nil(t, app.MigrateOnStart(context.Background(), storage.DB, cfg))
+
+	status, health = probe(handler, "/readyz")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "ok", health.Status)
+	assert.Len(t, health.Checks, 3)
+	for name, check := range health.Checks {
+		assert.Equal(t, "ok", check.Status, name)
+		assert.NotEmpty(t, check.Latency, name)
+	}
+
+	// shutting down, load balancers must stop sending requests
+	healthService.Drain()
+	status, health = probe(handler, "/readyz")
+	assert.Equal(t, 503, status)
+	assert.Equal(t, "shutting down", health.Checks["shutdown"].Error)
+
+	// still alive though
+	status, _ = probe(handler, "/healthz")
+	assert.Equal(t, 200, status)
+
+	// the database is gone
+	storage.DB.Close()
+	healthService, err = app.NewHealthService(storage.DB, cfg, config.HealthConfig{Timeout: time.Second})
+	assert.Nil(t, err)
+	status, health = probe(setupHealthRouter(t, healthService), "/readyz")
+	assert.Equal(t, 503, status)
+	assert.Equal(t, "fail", health.Checks["database"].Status)
+	assert.Equal(t, "ok", health.Checks["shutdown"].Status)
+
+	// the api still needs a key
+	status, _ = probe(handler, "/api/users")
+	assert.Equal(t, 401, status)
+}
+
+func TestHealthMemory(t *testing.T) {
+	healthService, err := app.NewHealthService(nil, config.DatabaseConfig{Driver: "memory"}, config.HealthConfig{Timeout: time.Second})
+	assert.Nil(t, err)
+
+	status, health := probe(setupHealthRouter(t, healthService), "/readyz")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []string{"shutdown"}, healthCheckNames(health.Checks))
+}
+
+func healthCheckNames(checks map[string]web.HealthCheck) []string {
+	var names []string
+	for name := range checks {
+		names = append(names, name)
+	}
+	return names
+}
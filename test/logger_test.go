@@ -0,0 +1,108 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the access log
+// and the error logs, written as json.
+
+// every line written to out, decoded
+func logLines(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var fields map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &fields), line)
+		lines = append(lines, fields)
+	}
+
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	storage, err := app.NewStorage(config.DatabaseConfig{Driver: "memory"})
+	assert.Nil(t, err)
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), nil)
+
+	out := &bytes.Buffer{}
+	logger := app.NewLogger(config.LogConfig{Format: "json", Level: "info"}, out)
+	handler := middleware.NewLoggerMiddleware(middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET"), logger)
+
+	issued, err := apiKeyService.Issue(context.Background(), web.APIKeyCreatePayload{
+		Name:   "reader",
+		Owner:  "john",
+		Scopes: []string{"users:read"},
+	})
+	assert.Nil(t, err)
+
+	found, _ := sendRequest(handler, http.MethodGet, "/api/users", "", "X-API-KEY", issued.Key)
+	foundBody, _ := io.ReadAll(found.Body)
+	sendRequest(handler, http.MethodGet, "/api/users/7", "", "X-API-KEY", issued.Key)
+	sendRequest(handler, http.MethodGet, "/api/users", "", "X-API-KEY", "WRONG")
+
+	lines := logLines(t, out)
+	assert.Len(t, lines, 3)
+
+	assert.Equal(t, "request", lines[0]["msg"])
+	assert.Equal(t, "INFO", lines[0]["level"])
+	assert.Equal(t, "GET", lines[0]["method"])
+	assert.Equal(t, "GET /api/users", lines[0]["route"])
+	assert.Equal(t, float64(200), lines[0]["status"])
+	assert.Equal(t, float64(len(foundBody)), lines[0]["bytes"])
+	assert.Equal(t, float64(issued.Id), lines[0]["key_id"])
+	assert.Contains(t, lines[0], "latency")
+
+	assert.Equal(t, "/api/users/7", lines[1]["path"])
+	assert.Equal(t, "GET /api/users/:userId", lines[1]["route"])
+	assert.Equal(t, float64(404), lines[1]["status"])
+
+	// rejected before reaching a route
+	assert.Equal(t, float64(401), lines[2]["status"])
+	assert.Equal(t, "", lines[2]["route"])
+	assert.NotContains(t, lines[2], "key_id")
+}
+
+func TestPanicLog(t *testing.T) {
+	out := &bytes.Buffer{}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(app.NewLogger(config.LogConfig{Format: "json", Level: "info"}, out))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users", nil)
+
+	exception.PanicHandler(recorder, request, "boom")
+
+	assert.Equal(t, 500, recorder.Code)
+	// the cause stays in the log
+	assert.NotContains(t, recorder.Body.String(), "boom")
+
+	lines := logLines(t, out)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "ERROR", lines[0]["level"])
+	assert.Equal(t, "panic", lines[0]["msg"])
+	assert.Equal(t, "boom", lines[0]["error"])
+	assert.Contains(t, lines[0]["stack"], "TestPanicLog")
+}
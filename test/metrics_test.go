@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/metrics"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the prometheus metrics,
+// read back from /metrics.
+
+func TestMetrics(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Driver = "sqlite"
+	cfg.DSN = "file:" + filepath.Join(t.TempDir(), "users.db") + "?_pragma=busy_timeout(5000)"
+	cfg.MigrateOnStart = true
+
+	storage, err := app.NewStorage(cfg)
+	assert.Nil(t, err)
+	defer storage.DB.Close()
+	assert.Nil(t, app.MigrateOnStart(context.Background(), storage.DB, cfg))
+	assert.Nil(t, metrics.RegisterDB(storage.DB, "metrics_test"))
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), nil)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET"))
+	mux.Handle("/metrics", metrics.Handler())
+	handler := middleware.NewMetricsMiddleware(mux)
+
+	requests := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "GET /api/users/:userId", "404"))
+
+	send := func(url string, apiKey string) (int, string) {
+		response, _ := sendRequest(handler, http.MethodGet, url, "", "X-API-KEY", apiKey)
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(body)
+	}
+
+	send("/api/users", "SECRET")
+	send("/api/users/7", "SECRET")
+	send("/api/users/8", "SECRET")
+	send("/api/users", "WRONG")
+
+	assert.Equal(t, requests+2, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "GET /api/users/:userId", "404")))
+
+	// made up methods share one label
+	for _, method := range []string{"FOO", "BAR"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "http://localhost:3000/api/nothing", nil))
+	}
+
+	// no api key is needed to scrape
+	status, body := send("/metrics", "")
+	assert.Equal(t, 200, status)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="GET /api/users",status="200"}`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="",status="401"}`)
+	assert.Contains(t, body, `http_requests_total{method="OTHER",route="",status="401"}`)
+	assert.NotContains(t, body, `method="FOO"`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="GET /api/users",status="200",le="+Inf"}`)
+	assert.Contains(t, body, `repository_duration_seconds_count{method="FindAll",repository="user",result="ok"}`)
+	assert.Contains(t, body, `repository_duration_seconds_count{method="FindById",repository="user",result="error"}`)
+	assert.Contains(t, body, `go_sql_open_connections{db_name="metrics_test"}`)
+
+	// panics are counted by the panic handler
+	panics := testutil.ToFloat64(metrics.Panics)
+	exception.PanicHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users", nil), "boom")
+	assert.Equal(t, panics+1, testutil.ToFloat64(metrics.Panics))
+}
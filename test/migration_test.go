@@ -0,0 +1,165 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/iqbaltaufiq/latihan-restapi/migration"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// migrations are run against a sqlite file,
+// the mysql test database is expected to be migrated already
+
+func setupMigrationDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "migrations.db")+"?_pragma=busy_timeout(5000)")
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db := setupMigrationDB(t)
+
+	migrator, err := migration.NewMigrator(db, "sqlite")
+	assert.Nil(t, err)
+
+	statuses, err := migrator.Status(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, statuses[0].Version)
+	assert.Nil(t, statuses[0].AppliedAt)
+
+	// status only reads, schema_migrations is created by up
+	var tables int
+	assert.Nil(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'").Scan(&tables))
+	assert.Equal(t, 0, tables)
+
+	applied, err := migrator.Up(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, applied, len(migrator.Migrations))
+
+	// applying twice does nothing
+	applied, err = migrator.Up(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+
+	_, err = db.Exec("INSERT INTO user(name, occupation) VALUES ('John', 'student')")
+	assert.Nil(t, err)
+
+	statuses, err = migrator.Status(context.Background())
+	assert.Nil(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.False(t, statuses[0].Changed)
+
+	rolledBack, err := migrator.Down(context.Background(), len(migrator.Migrations))
+	assert.Nil(t, err)
+	assert.Len(t, rolledBack, len(migrator.Migrations))
+
+	_, err = db.Exec("SELECT * FROM user")
+	assert.NotNil(t, err)
+}
+
+func TestMigrateChangedMigration(t *testing.T) {
+	db := setupMigrationDB(t)
+
+	migrator, err := migration.NewMigrator(db, "sqlite")
+	assert.Nil(t, err)
+
+	_, err = migrator.Up(context.Background())
+	assert.Nil(t, err)
+
+	// pretend the sql file was edited after being applied
+	_, err = db.Exec("UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1")
+	assert.Nil(t, err)
+
+	statuses, err := migrator.Status(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, statuses[0].Changed)
+
+	_, err = migrator.Up(context.Background())
+	assert.ErrorContains(t, err, "was changed after it was applied")
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	db := setupMigrationDB(t)
+
+	// every instance waits for the lock,
+	// only the first one has something to apply
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	applied := make([]int, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			migrator, err := migration.NewMigrator(db, "sqlite")
+			assert.Nil(t, err)
+
+			migrations, err := migrator.Up(context.Background())
+			errs[i] = err
+			applied[i] = len(migrations)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range errs {
+		assert.Nil(t, errs[i])
+		total += applied[i]
+	}
+
+	var rows int
+	db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&rows)
+	assert.NotZero(t, total)
+	assert.Equal(t, rows, total)
+}
+
+func TestMigrateExistingTable(t *testing.T) {
+	// the user table of the first release, made by hand
+	db := setupMigrationDB(t)
+	_, err := db.Exec("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(200) NOT NULL, occupation VARCHAR(200) NOT NULL)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO user(name, occupation) VALUES ('John', 'student')")
+	assert.Nil(t, err)
+
+	migrator, err := migration.NewMigrator(db, "sqlite")
+	assert.Nil(t, err)
+
+	// adopted, the rows are kept
+	_, err = migrator.Up(context.Background())
+	assert.Nil(t, err)
+
+	var version int
+	assert.Nil(t, db.QueryRow("SELECT version FROM user WHERE name = 'John'").Scan(&version))
+	assert.Equal(t, 1, version)
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	// the table documented in the readme before migrations,
+	// it already has version and deleted_at
+	db := setupMigrationDB(t)
+	_, err := db.Exec("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(200) NOT NULL, occupation VARCHAR(200) NOT NULL, version INTEGER NOT NULL DEFAULT 1, deleted_at DATETIME NULL)")
+	assert.Nil(t, err)
+
+	migrator, err := migration.NewMigrator(db, "sqlite")
+	assert.Nil(t, err)
+
+	recorded, err := migrator.Baseline(context.Background(), 4)
+	assert.Nil(t, err)
+	assert.Equal(t, "add_user_version_and_deleted_at", recorded.Name)
+
+	applied, err := migrator.Up(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, applied, len(migrator.Migrations)-1)
+
+	_, err = migrator.Baseline(context.Background(), 4)
+	assert.ErrorContains(t, err, "already applied")
+	_, err = migrator.Baseline(context.Background(), 99)
+	assert.ErrorContains(t, err, "not known")
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for users
+// being only visible to the caller who created them.
+
+func TestUserOwner(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	router := setupTokenRouter(t, config.JWTConfig{Secret: string(secret)})
+
+	token := func(subject string, role string) string {
+		return signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{
+			"sub":   subject,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{role},
+		})
+	}
+
+	send := func(method string, url string, token string, payload string) (int, interface{}) {
+		response, responseBody := sendRequest(router, method, url, payload, "Authorization", "Bearer "+token)
+		return response.StatusCode, responseBody["data"]
+	}
+
+	john := token("john", "owner")
+	anne := token("anne", "owner")
+	admin := token("root", "admin")
+
+	status, data := send(http.MethodPost, "/api/users", john, `{"name": "John", "occupation": "student"}`)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "john", data.(map[string]interface{})["owner"])
+
+	status, _ = send(http.MethodPost, "/api/users", anne, `{"name": "Anne", "occupation": "lecturer"}`)
+	assert.Equal(t, 200, status)
+
+	// anne can't tell john's user exists
+	requests := []struct {
+		method  string
+		url     string
+		payload string
+	}{
+		{http.MethodGet, "/api/users/1", ""},
+		{http.MethodPut, "/api/users/1", `{"name": "Jack", "occupation": "teacher"}`},
+		{http.MethodPatch, "/api/users/1", `{"name": "Jack"}`},
+		{http.MethodDelete, "/api/users/1", ""},
+	}
+	for _, request := range requests {
+		status, _ := send(request.method, request.url, anne, request.payload)
+		assert.Equal(t, 404, status, request.method+" "+request.url)
+	}
+
+	status, data = send(http.MethodGet, "/api/users", anne, "")
+	assert.Equal(t, 200, status)
+	assert.Len(t, data, 1)
+	assert.Equal(t, "Anne", data.([]interface{})[0].(map[string]interface{})["name"])
+
+	status, data = send(http.MethodGet, "/api/users/1", john, "")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "John", data.(map[string]interface{})["name"])
+
+	// admins see everyone's users
+	status, data = send(http.MethodGet, "/api/users", admin, "")
+	assert.Equal(t, 200, status)
+	assert.Len(t, data, 2)
+
+	status, data = send(http.MethodGet, "/api/users?owner=anne", admin, "")
+	assert.Equal(t, 200, status)
+	assert.Len(t, data, 1)
+
+	status, _ = send(http.MethodPut, "/api/users/1", admin, `{"name": "Jack", "occupation": "teacher"}`)
+	assert.Equal(t, 200, status)
+}
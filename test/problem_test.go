@@ -0,0 +1,83 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the error responses
+// in application/problem+json, picked by the Accept header.
+
+func TestProblemDetails(t *testing.T) {
+	router := setupStorageRouter(t, "memory")
+
+	send := func(method string, url string, accept string, apiKey string, payload string) (*http.Response, map[string]interface{}) {
+		request := newRequest(method, url, payload, "X-API-KEY", apiKey, "Accept", accept)
+		return serveRequest(router, request.WithContext(helper.WithRequestId(request.Context(), "abc")))
+	}
+
+	response, body := send(http.MethodGet, "/api/users/7", "application/problem+json", "SECRET", "")
+	assert.Equal(t, 404, response.StatusCode)
+	assert.Equal(t, "application/problem+json", response.Header.Get("Content-Type"))
+	assert.Equal(t, "Accept", response.Header.Get("Vary"))
+	assert.Equal(t, "urn:latihan-restapi:problem:not-found", body["type"])
+	assert.Equal(t, "Not Found", body["title"])
+	assert.Equal(t, float64(404), body["status"])
+	assert.Equal(t, "user not found", body["detail"])
+	assert.Equal(t, "/api/users/7", body["instance"])
+	assert.Equal(t, "abc", body["request_id"])
+
+	// routes that don't exist are answered the same way
+	response, body = send(http.MethodGet, "/api/nothing", "application/problem+json", "SECRET", "")
+	assert.Equal(t, 404, response.StatusCode)
+	assert.Equal(t, "urn:latihan-restapi:problem:not-found", body["type"])
+	assert.Equal(t, "abc", body["request_id"])
+
+	response, body = send(http.MethodDelete, "/api/users", "", "SECRET", "")
+	assert.Equal(t, 405, response.StatusCode)
+	assert.Equal(t, "GET, OPTIONS, POST", response.Header.Get("Allow"))
+	assert.Equal(t, "method DELETE not allowed, use GET, OPTIONS, POST", body["data"])
+	assert.Equal(t, "abc", body["request_id"])
+
+	// rejected by auth, before the router
+	response, body = send(http.MethodGet, "/api/users", "application/problem+json, application/json;q=0.5", "WRONG", "")
+	assert.Equal(t, 401, response.StatusCode)
+	assert.Equal(t, "urn:latihan-restapi:problem:unauthorized", body["type"])
+
+	response, body = send(http.MethodPost, "/api/users", "application/problem+json", "SECRET", `{"name": "", "occupation": "student"}`)
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Equal(t, "urn:latihan-restapi:problem:validation", body["type"])
+
+	// existing clients keep the envelope
+	for _, accept := range []string{"", "*/*", "application/json", "application/json, application/problem+json;q=0.5", "application/*"} {
+		response, body = send(http.MethodGet, "/api/users/7", accept, "SECRET", "")
+		assert.Equal(t, "application/json", response.Header.Get("Content-Type"), accept)
+		assert.Equal(t, float64(404), body["code"], accept)
+		assert.Equal(t, "user not found", body["data"], accept)
+	}
+
+	// the panic value isn't sent
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users", nil)
+	request.Header.Add("Accept", "application/problem+json")
+	exception.PanicHandler(recorder, request, "boom")
+
+	assert.Equal(t, 500, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "boom")
+	assert.Contains(t, recorder.Body.String(), `"type":"about:blank"`)
+	assert.Contains(t, recorder.Body.String(), `"detail":"Internal Server Error"`)
+}
+
+func TestAcceptQuality(t *testing.T) {
+	assert.Equal(t, 1.0, helper.AcceptQuality("", "application/json"))
+	assert.Equal(t, 0.0, helper.AcceptQuality("text/html", "application/json"))
+	assert.Equal(t, 0.5, helper.AcceptQuality("text/html, */*;q=0.5", "application/json"))
+	// the most specific range wins
+	assert.Equal(t, 0.0, helper.AcceptQuality("application/problem+json;q=0, */*", "application/problem+json"))
+	assert.Equal(t, 0.8, helper.AcceptQuality("application/*;q=0.8, */*;q=0.1", "application/json"))
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the rate limit of the routes,
+// with users kept in memory.
+
+func TestRateLimit(t *testing.T) {
+	storage, err := app.NewStorage(config.DatabaseConfig{Driver: "memory"})
+	assert.Nil(t, err)
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+
+	// 2 requests an hour on every route, 3 on GET /api/users/:userId
+	limiter := app.NewRateLimiter(config.RateLimitConfig{
+		Requests: 2,
+		Period:   time.Hour,
+		Routes: map[string]config.RateLimitRule{
+			"GET /api/users/:userId": {Requests: 3, Period: time.Hour},
+		},
+	})
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), limiter)
+	handler := middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET")
+
+	issued, err := apiKeyService.Issue(context.Background(), web.APIKeyCreatePayload{
+		Name:   "reader",
+		Owner:  "john",
+		Scopes: []string{"users:read"},
+	})
+	assert.Nil(t, err)
+
+	send := func(url string, apiKey string, remoteAddr string) *http.Response {
+		request := newRequest(http.MethodGet, url, "", "X-API-KEY", apiKey)
+		request.RemoteAddr = remoteAddr
+
+		response, _ := serveRequest(handler, request)
+		return response
+	}
+
+	// the config key has no id, it is counted by its subject whatever its ip
+	response := send("/api/users", "SECRET", "192.0.2.1:1234")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "2", response.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "1", response.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, "1800", response.Header.Get("RateLimit-Reset"))
+
+	response = send("/api/users", "SECRET", "192.0.2.2:5678")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "0", response.Header.Get("RateLimit-Remaining"))
+
+	response = send("/api/users", "SECRET", "192.0.2.3:1234")
+	assert.Equal(t, 429, response.StatusCode)
+	assert.Equal(t, "0", response.Header.Get("RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.InDelta(t, 1800, retryAfter, 1)
+
+	body, _ := io.ReadAll(response.Body)
+	var responseBody web.HttpResponse
+	json.Unmarshal(body, &responseBody)
+	assert.Equal(t, 429, responseBody.Code)
+	assert.Equal(t, "Too Many Requests", responseBody.Status)
+
+	// an api key has its own bucket, whatever its ip
+	for i := 0; i < 2; i++ {
+		response = send("/api/users", issued.Key, "192.0.2.1:1234")
+		assert.Equal(t, 200, response.StatusCode)
+	}
+	response = send("/api/users", issued.Key, "192.0.2.3:1234")
+	assert.Equal(t, 429, response.StatusCode)
+
+	// and every route, with its own limit
+	for i := 0; i < 3; i++ {
+		response = send("/api/users/1", "SECRET", "192.0.2.1:1234")
+		assert.Equal(t, "3", response.Header.Get("RateLimit-Limit"))
+		assert.NotEqual(t, 429, response.StatusCode)
+	}
+	response = send("/api/users/1", "SECRET", "192.0.2.1:1234")
+	assert.Equal(t, 429, response.StatusCode)
+}
+
+func TestRateLimitFailedAuth(t *testing.T) {
+	storage, err := app.NewStorage(config.DatabaseConfig{Driver: "memory"})
+	assert.Nil(t, err)
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+
+	// 3 failed authentications an hour for every ip address
+	limiter := app.NewRateLimiter(config.RateLimitConfig{
+		FailedAuth: config.RateLimitRule{Requests: 3, Period: time.Hour},
+	})
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), limiter)
+	handler := middleware.NewFailedAuthMiddleware(middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET"), limiter)
+
+	send := func(apiKey string, remoteAddr string) *http.Response {
+		request := newRequest(http.MethodGet, "/api/users", "", "X-API-KEY", apiKey)
+		request.RemoteAddr = remoteAddr
+
+		response, _ := serveRequest(handler, request)
+		return response
+	}
+
+	// successful requests aren't counted
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 200, send("SECRET", "192.0.2.1:1234").StatusCode)
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 401, send("WRONG"+strconv.Itoa(i), "192.0.2.1:1234").StatusCode)
+	}
+
+	// the keys aren't checked anymore, even a good one
+	response := send("WRONG", "192.0.2.1:1234")
+	assert.Equal(t, 429, response.StatusCode)
+	retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.InDelta(t, 1200, retryAfter, 1)
+	assert.Equal(t, 429, send("SECRET", "192.0.2.1:1234").StatusCode)
+
+	// another ip has its own bucket
+	assert.Equal(t, 401, send("WRONG", "192.0.2.2:1234").StatusCode)
+	assert.Equal(t, 200, send("SECRET", "192.0.2.2:1234").StatusCode)
+
+	// behind a proxy the client is the address the proxy appended,
+	// the ones sent by the client don't give it a new bucket
+	limiter.TrustForwardedFor = true
+	sendForwarded := func(forwardedFor string) *http.Response {
+		request := newRequest(http.MethodGet, "/api/users", "", "X-API-KEY", "WRONG", "X-Forwarded-For", forwardedFor)
+		request.RemoteAddr = "10.0.0.1:1234"
+
+		response, _ := serveRequest(handler, request)
+		return response
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 401, sendForwarded("203.0.113."+strconv.Itoa(i)+", 198.51.100.7").StatusCode)
+	}
+	assert.Equal(t, 429, sendForwarded("203.0.113.99, 198.51.100.7").StatusCode)
+	assert.Equal(t, 401, sendForwarded("198.51.100.8").StatusCode)
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the json
+// request bodies that are refused before reaching the service.
+
+func TestStrictRequestBody(t *testing.T) {
+	router := middleware.NewBodyLimitMiddleware(setupStorageRouter(t, "memory"), 64)
+
+	send := func(method string, url string, contentType string, payload string) (int, string) {
+		response, responseBody := sendRequest(router, method, url, payload, "Content-Type", contentType, "X-API-KEY", "SECRET")
+		data, _ := responseBody["data"].(string)
+		return response.StatusCode, data
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		payload     string
+		status      int
+		message     string
+	}{
+		{"valid", http.MethodPost, "/api/users", "application/json; charset=utf-8", `{"name": "John", "occupation": "student"}`, 200, ""},
+		{"unknown field", http.MethodPost, "/api/users", "application/json", `{"name": "John", "occupation": "student", "age": 20}`, 400, `unknown field "age"`},
+		{"trailing data", http.MethodPost, "/api/users", "application/json", `{"name": "John", "occupation": "student"} {}`, 400, "request body must be a single json value"},
+		{"trailing garbage", http.MethodPost, "/api/users", "application/json", `{"name": "John", "occupation": "student"}x`, 400, "request body must be a single json value"},
+		{"wrong type", http.MethodPost, "/api/users", "application/json", `{"name": 7, "occupation": "student"}`, 400, "name must be a string"},
+		{"not an object", http.MethodPost, "/api/users", "application/json", `["John"]`, 400, "request body must be an object"},
+		{"malformed", http.MethodPost, "/api/users", "application/json", `{"name": "John",}`, 400, "request body is malformed json at offset 17"},
+		{"cut short", http.MethodPost, "/api/users", "application/json", `{"name": "John"`, 400, "request body is malformed json: unexpected end"},
+		{"empty", http.MethodPost, "/api/users", "application/json", ``, 400, "request body is empty"},
+		{"no content type", http.MethodPost, "/api/users", "", `{"name": "John", "occupation": "student"}`, 415, "request body must be application/json"},
+		{"form", http.MethodPost, "/api/users", "application/x-www-form-urlencoded", `name=John`, 415, "request body must be application/json"},
+		{"too large", http.MethodPost, "/api/users", "application/json", `{"name": "` + strings.Repeat("a", 100) + `"}`, 413, "request body must not be larger than 64 bytes"},
+		{"update wrong type", http.MethodPut, "/api/users/1", "application/json", `{"name": "John", "occupation": "student", "version": "1"}`, 400, "version must be an integer"},
+		{"bulk not an array", http.MethodPost, "/api/bulk/users", "application/json", `{"name": "John"}`, 400, "request body must be an array"},
+		{"bulk item wrong type", http.MethodPost, "/api/bulk/users", "application/json", `[{"name": 7}]`, 400, "$[0].name must be a string"},
+		{"bulk ids", http.MethodDelete, "/api/bulk/users", "application/json", `[1, "2"]`, 400, "$[1] must be an integer"},
+		{"api key", http.MethodPost, "/api/admin/keys", "application/json", `{"owner": "john", "scope": ["users:read"]}`, 400, `unknown field "scope"`},
+		{"patch too large", http.MethodPatch, "/api/users/1", "application/merge-patch+json", `{"name": "` + strings.Repeat("a", 100) + `"}`, 413, "request body must not be larger than 64 bytes"},
+	}
+
+	for _, test := range tests {
+		status, message := send(test.method, test.url, test.contentType, test.payload)
+		assert.Equal(t, test.status, status, test.name)
+		if test.message != "" {
+			assert.Equal(t, test.message, message, test.name)
+		}
+	}
+}
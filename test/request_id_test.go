@@ -0,0 +1,89 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the request id,
+// from the header down to the repository logs.
+
+func TestRequestId(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Driver = "sqlite"
+	cfg.DSN = "file:" + filepath.Join(t.TempDir(), "users.db") + "?_pragma=busy_timeout(5000)"
+	cfg.MigrateOnStart = true
+
+	storage, err := app.NewStorage(cfg)
+	assert.Nil(t, err)
+	defer storage.DB.Close()
+	assert.Nil(t, app.MigrateOnStart(context.Background(), storage.DB, cfg))
+
+	// the repository logs through the default logger
+	out := &bytes.Buffer{}
+	logger := app.NewLogger(config.LogConfig{Format: "json", Level: "debug"}, out)
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), nil)
+	authMiddleware := middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET")
+	handler := middleware.NewRequestIdMiddleware(middleware.NewLoggerMiddleware(authMiddleware, logger))
+
+	send := func(url string, requestId string) (*http.Response, web.HttpResponse) {
+		response, _ := sendRequest(handler, http.MethodGet, url, "", "X-API-KEY", "SECRET", "X-Request-ID", requestId)
+
+		var responseBody web.HttpResponse
+		json.NewDecoder(response.Body).Decode(&responseBody)
+		return response, responseBody
+	}
+
+	// the id of the client is echoed in the header and the error body
+	response, body := send("/api/users/7", "trace-1")
+	assert.Equal(t, 404, response.StatusCode)
+	assert.Equal(t, "trace-1", response.Header.Get("X-Request-ID"))
+	assert.Equal(t, "trace-1", body.RequestId)
+
+	// and every log line of the request has it, the sql ones too
+	lines := logLines(t, out)
+	assert.NotEmpty(t, lines)
+	var messages []interface{}
+	for _, line := range lines {
+		assert.Equal(t, "trace-1", line["request_id"], line["msg"])
+		messages = append(messages, line["msg"])
+	}
+	assert.Contains(t, messages, "user repository query")
+	assert.Contains(t, messages, "request")
+
+	// a new one is made when missing or not printable
+	response, body = send("/api/users/7", "")
+	generated := response.Header.Get("X-Request-ID")
+	assert.Len(t, generated, 32)
+	assert.Equal(t, generated, body.RequestId)
+
+	response, _ = send("/api/users/7", "bad id\n")
+	assert.Len(t, response.Header.Get("X-Request-ID"), 32)
+
+	// successful bodies don't carry it
+	response, body = send("/api/users", "trace-2")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "trace-2", response.Header.Get("X-Request-ID"))
+	assert.Equal(t, "", body.RequestId)
+}
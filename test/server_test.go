@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the shutdown of the server,
+// with a real listener.
+
+// serve handler until the returned cancel is called,
+// RunServer's result is sent to the channel
+func startServer(t *testing.T, cfg config.ServerConfig, handler http.Handler) (string, context.CancelFunc, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	healthService, err := app.NewHealthService(nil, config.DatabaseConfig{Driver: "memory"}, config.HealthConfig{Timeout: time.Second})
+	assert.Nil(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.Handle("/readyz", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(healthService.Ready(request.Context()).Status))
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.RunServer(ctx, app.NewServer(cfg, mux), listener, cfg, healthService)
+	}()
+
+	return "http://" + listener.Addr().String(), cancel, done
+}
+
+func httpGet(url string) (string, error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	return string(body), err
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.DrainDelay = 200 * time.Millisecond
+	cfg.ShutdownTimeout = time.Second
+
+	started := make(chan bool)
+	url, cancel, done := startServer(t, cfg, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		writer.Write([]byte("finished"))
+	}))
+
+	body, err := httpGet(url + "/readyz")
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", body)
+
+	slow := make(chan string)
+	go func() {
+		body, _ := httpGet(url + "/slow")
+		slow <- body
+	}()
+	<-started
+
+	// SIGTERM
+	cancel()
+
+	// still serving while the load balancer notices
+	time.Sleep(50 * time.Millisecond)
+	body, err = httpGet(url + "/readyz")
+	assert.Nil(t, err)
+	assert.Equal(t, "fail", body)
+
+	// the request in flight is finished, then the server stops
+	assert.Equal(t, "finished", <-slow)
+	assert.Nil(t, <-done)
+
+	_, err = httpGet(url + "/readyz")
+	assert.NotNil(t, err)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = 100 * time.Millisecond
+
+	started := make(chan bool)
+	url, cancel, done := startServer(t, cfg, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		time.Sleep(2 * time.Second)
+	}))
+
+	go httpGet(url + "/stuck")
+	<-started
+
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "requests still running after 100ms")
+	case <-time.After(time.Second):
+		t.Fatal("shutdown didn't stop at its deadline")
+	}
+}
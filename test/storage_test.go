@@ -0,0 +1,183 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// The same requests are sent to every backend that doesn't need
+// a database server, to make sure they all behave like the mysql one.
+
+func setupStorageRouter(t *testing.T, driver string) http.Handler {
+	cfg := config.Default().Database
+	cfg.Driver = driver
+	cfg.DSN = ""
+
+	if driver == "sqlite" {
+		cfg.DSN = "file:" + filepath.Join(t.TempDir(), "users.db") + "?_pragma=busy_timeout(5000)"
+	}
+
+	storage, err := app.NewStorage(cfg)
+	assert.Nil(t, err)
+
+	if storage.DB != nil {
+		t.Cleanup(func() { storage.DB.Close() })
+
+		cfg.MigrateOnStart = true
+		err := app.MigrateOnStart(context.Background(), storage.DB, cfg)
+		assert.Nil(t, err)
+	}
+
+	validate, err := app.NewValidator()
+	assert.Nil(t, err)
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	userController := controller.NewUserController(userService)
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
+
+	return middleware.NewAuthMiddleware(router.NewRouter(userController, apiKeyController, nil), apiKeyService, nil, "SECRET")
+}
+
+// a request as a client would send it.
+// headers are pairs of name and value, e.g. "X-API-KEY", "SECRET",
+// an empty value leaves the header out. the payload is sent as
+// application/json unless a Content-Type is given
+func newRequest(method string, url string, payload string, headers ...string) *http.Request {
+	request := httptest.NewRequest(method, "http://localhost:3000"+url, strings.NewReader(payload))
+	request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i+1] == "" {
+			request.Header.Del(headers[i])
+		} else {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+	}
+
+	return request
+}
+
+// serve request with handler and decode the json of the response.
+// the body of the response can still be read as it is
+func serveRequest(handler http.Handler, request *http.Request) (*http.Response, map[string]interface{}) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	body, _ := io.ReadAll(response.Body)
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+	return response, responseBody
+}
+
+// send a request to handler, see newRequest and serveRequest
+func sendRequest(handler http.Handler, method string, url string, payload string, headers ...string) (*http.Response, map[string]interface{}) {
+	return serveRequest(handler, newRequest(method, url, payload, headers...))
+}
+
+func TestStorageBackends(t *testing.T) {
+	for _, driver := range []string{"memory", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			router := setupStorageRouter(t, driver)
+
+			send := func(method string, url string, payload string, headers ...string) (*http.Response, map[string]interface{}) {
+				return sendRequest(router, method, url, payload, append([]string{"X-API-KEY", "SECRET"}, headers...)...)
+			}
+
+			names := func(responseBody map[string]interface{}) []string {
+				var names []string
+				for _, user := range responseBody["data"].([]interface{}) {
+					names = append(names, user.(map[string]interface{})["name"].(string))
+				}
+				return names
+			}
+
+			response, _ := send(http.MethodPost, "/api/bulk/users", `[{"name": "Carl", "occupation": "student"}, {"name": "Anne", "occupation": "lecturer"}, {"name": "Bob", "occupation": "student"}]`)
+			assert.Equal(t, 200, response.StatusCode)
+
+			response, responseBody := send(http.MethodPost, "/api/users", `{"name": "Dave", "occupation": "student"}`)
+			assert.Equal(t, 200, response.StatusCode)
+			daveId := int(responseBody["data"].(map[string]interface{})["id"].(float64))
+			assert.Equal(t, 4, daveId)
+
+			_, responseBody = send(http.MethodGet, "/api/users?occupation=student&sort=-name", "")
+			assert.Equal(t, []string{"Dave", "Carl", "Bob"}, names(responseBody))
+			assert.Equal(t, float64(3), responseBody["meta"].(map[string]interface{})["total_items"])
+
+			// walk through the users with a cursor
+			_, responseBody = send(http.MethodGet, "/api/users?limit=3&sort=name", "")
+			assert.Equal(t, []string{"Anne", "Bob", "Carl"}, names(responseBody))
+			cursor := responseBody["meta"].(map[string]interface{})["next_cursor"].(string)
+
+			_, responseBody = send(http.MethodGet, "/api/users?limit=3&after="+cursor, "")
+			assert.Equal(t, []string{"Dave"}, names(responseBody))
+			assert.Nil(t, responseBody["meta"].(map[string]interface{})["next_cursor"])
+
+			// the cursor is bound to the query it was made for
+			response, _ = send(http.MethodGet, "/api/users?limit=3&occupation=student&after="+cursor, "")
+			assert.Equal(t, 400, response.StatusCode)
+			response, _ = send(http.MethodGet, "/api/users?limit=3&include_deleted=true&after="+cursor, "")
+			assert.Equal(t, 400, response.StatusCode)
+
+			response, _ = send(http.MethodPut, fmt.Sprintf("/api/users/%d", daveId), `{"name": "David", "occupation": "teacher"}`, "If-Match", `"1"`)
+			assert.Equal(t, 200, response.StatusCode)
+			assert.Equal(t, `"2"`, response.Header.Get("ETag"))
+
+			response, _ = send(http.MethodDelete, fmt.Sprintf("/api/users/%d", daveId), "", "If-Match", `"1"`)
+			assert.Equal(t, 412, response.StatusCode)
+
+			response, _ = send(http.MethodDelete, fmt.Sprintf("/api/users/%d", daveId), "", "If-Match", `"2"`)
+			assert.Equal(t, 200, response.StatusCode)
+
+			response, _ = send(http.MethodGet, fmt.Sprintf("/api/users/%d", daveId), "")
+			assert.Equal(t, 404, response.StatusCode)
+
+			response, responseBody = send(http.MethodGet, fmt.Sprintf("/api/users/%d?include_deleted=true", daveId), "")
+			assert.Equal(t, 200, response.StatusCode)
+			assert.NotNil(t, responseBody["data"].(map[string]interface{})["deleted_at"])
+
+			response, responseBody = send(http.MethodPost, fmt.Sprintf("/api/users/%d/restore", daveId), "")
+			assert.Equal(t, 200, response.StatusCode)
+			assert.Equal(t, "David", responseBody["data"].(map[string]interface{})["name"])
+
+			// a failed atomic bulk request leaves nothing behind
+			response, _ = send(http.MethodPut, "/api/bulk/users", fmt.Sprintf(`[{"id": 1, "name": "Carla", "occupation": "student"}, {"id": %d, "name": "Dave", "occupation": "student", "version": 1}]`, daveId))
+			assert.Equal(t, 422, response.StatusCode)
+
+			_, responseBody = send(http.MethodGet, "/api/users/1", "")
+			assert.Equal(t, "Carl", responseBody["data"].(map[string]interface{})["name"])
+
+			// issue an api key, use it, then revoke it
+			response, responseBody = send(http.MethodPost, "/api/admin/keys", `{"name": "demo", "owner": "john", "scopes": ["users:read"]}`)
+			assert.Equal(t, 200, response.StatusCode)
+			key := responseBody["data"].(map[string]interface{})["key"].(string)
+			keyId := int(responseBody["data"].(map[string]interface{})["id"].(float64))
+
+			response, _ = send(http.MethodGet, "/api/users", "", "X-API-KEY", key)
+			assert.Equal(t, 200, response.StatusCode)
+
+			response, _ = send(http.MethodDelete, fmt.Sprintf("/api/admin/keys/%d", keyId), "")
+			assert.Equal(t, 200, response.StatusCode)
+
+			response, _ = send(http.MethodGet, "/api/users", "", "X-API-KEY", key)
+			assert.Equal(t, 401, response.StatusCode)
+		})
+	}
+}
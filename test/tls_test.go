@@ -0,0 +1,214 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for https, with client
+// certificates and the certificate changed while serving.
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// sign a certificate with parent, self signed when parent is nil
+func newTestCert(t *testing.T, serial int64, subject pkix.Name, parent *testCert) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer := testCert{cert: template, key: key}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer = *parent
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	return testCert{cert: cert, key: key}
+}
+
+// write the certificate and its key as pem
+func (c testCert) write(t *testing.T, certFile string, keyFile string) {
+	err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600)
+	assert.Nil(t, err)
+
+	if keyFile != "" {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		assert.Nil(t, err)
+		err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+		assert.Nil(t, err)
+	}
+}
+
+func (c testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, pkix.Name{CommonName: "test ca"}, nil)
+	ca.write(t, filepath.Join(dir, "ca.pem"), "")
+	server := newTestCert(t, 2, pkix.Name{CommonName: "localhost"}, &ca)
+	server.write(t, filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	client := newTestCert(t, 3, pkix.Name{CommonName: "john", OrganizationalUnit: []string{"owner"}}, &ca)
+
+	cfg := config.Default().Server
+	cfg.TLS = config.TLSConfig{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+
+	tlsConfig, err := app.NewTLSConfig(cfg.TLS)
+	assert.Nil(t, err)
+
+	healthService, err := app.NewHealthService(nil, config.DatabaseConfig{Driver: "memory"}, config.HealthConfig{Timeout: time.Second})
+	assert.Nil(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	httpServer := app.NewServer(cfg, setupStorageRouter(t, "memory"))
+	httpServer.TLSConfig = tlsConfig
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.RunServer(ctx, httpServer, listener, cfg, healthService)
+
+	url := "https://" + listener.Addr().String()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// a new connection every time, to see the certificate as it is now
+	send := func(certificates []tls.Certificate, method string, path string, payload string) (*http.Response, map[string]interface{}) {
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates},
+		}}
+		defer httpClient.CloseIdleConnections()
+
+		request, err := http.NewRequest(method, url+path, strings.NewReader(payload))
+		assert.Nil(t, err)
+		request.Header.Add("Content-Type", "application/json")
+
+		response, err := httpClient.Do(request)
+		if !assert.Nil(t, err) {
+			return nil, nil
+		}
+		defer response.Body.Close()
+
+		body, _ := io.ReadAll(response.Body)
+		var responseBody map[string]interface{}
+		json.Unmarshal(body, &responseBody)
+		return response, responseBody
+	}
+
+	// the certificate is the caller
+	response, body := send([]tls.Certificate{client.tlsCertificate()}, http.MethodPost, "/api/users", `{"name": "John", "occupation": "student"}`)
+	if assert.NotNil(t, response) {
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Equal(t, "john", body["data"].(map[string]interface{})["owner"])
+		assert.Equal(t, big.NewInt(2), response.TLS.PeerCertificates[0].SerialNumber)
+	}
+
+	// no certificate and no api key
+	response, _ = send(nil, http.MethodGet, "/api/users", "")
+	if assert.NotNil(t, response) {
+		assert.Equal(t, 401, response.StatusCode)
+	}
+
+	// a certificate of another CA isn't trusted,
+	// it is sent anyway instead of being left out by the client
+	stranger := newTestCert(t, 4, pkix.Name{CommonName: "anne"}, nil).tlsCertificate()
+	httpClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &stranger, nil
+		}},
+	}}
+	_, err = httpClient.Get(url + "/api/users")
+	assert.NotNil(t, err)
+
+	// renewed on disk, the next connection gets it without restarting
+	time.Sleep(1100 * time.Millisecond)
+	renewed := newTestCert(t, 5, pkix.Name{CommonName: "localhost"}, &ca)
+	renewed.write(t, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+
+	response, _ = send([]tls.Certificate{client.tlsCertificate()}, http.MethodGet, "/api/users", "")
+	if assert.NotNil(t, response) {
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Equal(t, big.NewInt(5), response.TLS.PeerCertificates[0].SerialNumber)
+	}
+}
+
+func TestTLSRedirect(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.Addr = ":8443"
+	cfg.TLS.RedirectAddr = ":8080"
+
+	redirectServer := app.NewRedirectServer(cfg)
+	assert.Equal(t, ":8080", redirectServer.Addr)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/users?page=2", nil)
+	redirectServer.Handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusPermanentRedirect, recorder.Code)
+	assert.Equal(t, "https://localhost:8443/api/users?page=2", recorder.Header().Get("Location"))
+}
+
+func TestTLSConfigValidation(t *testing.T) {
+	// plain http
+	tlsConfig, err := app.NewTLSConfig(config.TLSConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, tlsConfig)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "cert.pem"), []byte("not a certificate"), 0600)
+	os.WriteFile(filepath.Join(dir, "key.pem"), []byte("not a key"), 0600)
+
+	_, err = app.NewTLSConfig(config.TLSConfig{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")})
+	assert.ErrorContains(t, err, "load tls certificate")
+
+	// the key is required with the certificate
+	t.Setenv("APP_AUTH_API_KEY", "x")
+	t.Setenv("APP_SERVER_TLS_CERT_FILE", filepath.Join(dir, "cert.pem"))
+	_, err = config.Load("")
+	assert.ErrorContains(t, err, "KeyFile")
+}
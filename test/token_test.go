@@ -0,0 +1,152 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the bearer token mode
+// of the auth middleware, with users kept in memory.
+
+func setupTokenRouter(t *testing.T, cfg config.JWTConfig) http.Handler {
+	storage, err := app.NewStorage(config.DatabaseConfig{Driver: "memory"})
+	assert.Nil(t, err)
+
+	tokenService, err := app.NewTokenService(cfg)
+	assert.Nil(t, err)
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), nil)
+
+	return middleware.NewAuthMiddleware(router, apiKeyService, tokenService, "")
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return signed
+}
+
+func bearerStatus(router http.Handler, url string, token string) (int, string) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000"+url, nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	return response.StatusCode, response.Header.Get("WWW-Authenticate")
+}
+
+func TestBearerTokens(t *testing.T) {
+	dir := t.TempDir()
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	// RS256 public key in a pem file
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	assert.Nil(t, err)
+	pemFile := filepath.Join(dir, "public.pem")
+	os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+
+	// EdDSA public key in a JWKS file
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	os.WriteFile(jwksFile, []byte(`{"keys": [{"kty": "OKP", "crv": "Ed25519", "kid": "ed-1", "use": "sig", "x": "`+base64.RawURLEncoding.EncodeToString(edPublic)+`"}]}`), 0600)
+
+	router := setupTokenRouter(t, config.JWTConfig{
+		Secret:        string(secret),
+		PublicKeyFile: pemFile,
+		JWKSFile:      jwksFile,
+		Issuer:        "https://auth.example.com",
+		Audience:      "latihan-restapi",
+	})
+
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"sub":   "john",
+			"iss":   "https://auth.example.com",
+			"aud":   "latihan-restapi",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "users:read",
+		}
+		for name, value := range changes {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	valid := map[string]string{
+		"HS256": signToken(t, jwt.SigningMethodHS256, "", secret, claims(nil)),
+		"RS256": signToken(t, jwt.SigningMethodRS256, "", rsaKey, claims(nil)),
+		"EdDSA": signToken(t, jwt.SigningMethodEdDSA, "ed-1", edPrivate, claims(nil)),
+	}
+	for algorithm, token := range valid {
+		status, _ := bearerStatus(router, "/api/users", token)
+		assert.Equal(t, 200, status, algorithm)
+	}
+
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	invalid := map[string]string{
+		"expired":        signToken(t, jwt.SigningMethodHS256, "", secret, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"no exp":         signToken(t, jwt.SigningMethodHS256, "", secret, claims(jwt.MapClaims{"exp": nil})),
+		"not yet valid":  signToken(t, jwt.SigningMethodHS256, "", secret, claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"wrong issuer":   signToken(t, jwt.SigningMethodHS256, "", secret, claims(jwt.MapClaims{"iss": "https://evil.example.com"})),
+		"wrong audience": signToken(t, jwt.SigningMethodHS256, "", secret, claims(jwt.MapClaims{"aud": "another-api"})),
+		"no subject":     signToken(t, jwt.SigningMethodHS256, "", secret, claims(jwt.MapClaims{"sub": nil})),
+		"wrong secret":   signToken(t, jwt.SigningMethodHS256, "", []byte("another secret, long enough to pass"), claims(nil)),
+		"unknown signer": signToken(t, jwt.SigningMethodRS256, "", otherRSAKey, claims(nil)),
+		"unknown kid":    signToken(t, jwt.SigningMethodEdDSA, "ed-2", edPrivate, claims(nil)),
+		"alg none":       signToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims(nil)),
+		"garbage":        "not.a.token",
+	}
+	for reason, token := range invalid {
+		status, challenge := bearerStatus(router, "/api/users", token)
+		assert.Equal(t, 401, status, reason)
+		assert.Equal(t, `Bearer error="invalid_token"`, challenge, reason)
+	}
+
+	// scopes of the token reach the handlers
+	status, _ := bearerStatus(router, "/api/admin/keys", valid["HS256"])
+	assert.Equal(t, 403, status)
+
+	admin := signToken(t, jwt.SigningMethodRS256, "", rsaKey, claims(jwt.MapClaims{"scope": nil, "scp": []string{"users:read", "admin"}}))
+	status, _ = bearerStatus(router, "/api/admin/keys", admin)
+	assert.Equal(t, 200, status)
+
+	// without keys, bearer tokens are refused
+	router = setupTokenRouter(t, config.JWTConfig{})
+	status, _ = bearerStatus(router, "/api/users", valid["HS256"])
+	assert.Equal(t, 401, status)
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
+	"github.com/iqbaltaufiq/latihan-restapi/config"
+	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/middleware"
+	"github.com/iqbaltaufiq/latihan-restapi/router"
+	"github.com/iqbaltaufiq/latihan-restapi/service"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// This is an integration testing for the spans
+// of a request, from the router down to sql.
+
+func setupTracingRouter(t *testing.T) http.Handler {
+	cfg := config.Default().Database
+	cfg.Driver = "sqlite"
+	cfg.DSN = "file:" + filepath.Join(t.TempDir(), "users.db") + "?_pragma=busy_timeout(5000)"
+	cfg.MigrateOnStart = true
+
+	storage, err := app.NewStorage(cfg)
+	assert.Nil(t, err)
+	t.Cleanup(func() { storage.DB.Close() })
+	assert.Nil(t, app.MigrateOnStart(context.Background(), storage.DB, cfg))
+
+	validate := validator.New()
+	userService := service.NewUserService(storage.UserRepository, storage.Transactor, validate, []byte("SECRET"))
+	apiKeyService := service.NewAPIKeyService(storage.APIKeyRepository, storage.Transactor, validate)
+	router := router.NewRouter(controller.NewUserController(userService), controller.NewAPIKeyController(apiKeyService), nil)
+
+	return middleware.NewTracingMiddleware(middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET"))
+}
+
+func sendTraced(handler http.Handler, method string, url string, payload string, traceparent string) int {
+	response, _ := sendRequest(handler, method, url, payload, "X-API-KEY", "SECRET", "traceparent", traceparent)
+	return response.StatusCode
+}
+
+func TestTracing(t *testing.T) {
+	// sets the traceparent propagator
+	_, err := app.StartTracing(context.Background(), config.TracingConfig{Exporter: "none"}, nil)
+	assert.Nil(t, err)
+
+	spans := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	handler := setupTracingRouter(t)
+
+	status := sendTraced(handler, http.MethodPost, "/api/users", `{"name": "John", "occupation": "student"}`, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, 200, status)
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = span
+	}
+
+	// the trace of the caller goes on
+	server := byName["POST /api/users"]
+	if assert.NotNil(t, server) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	}
+
+	// route > service > transaction > sql
+	service := byName["UserService.Create"]
+	transaction := byName["transaction"]
+	insert := byName["INSERT"]
+	if assert.NotNil(t, service) && assert.NotNil(t, transaction) && assert.NotNil(t, insert) {
+		assert.Equal(t, server.SpanContext().SpanID(), service.Parent().SpanID())
+		assert.Equal(t, service.SpanContext().SpanID(), transaction.Parent().SpanID())
+		assert.Equal(t, transaction.SpanContext().SpanID(), insert.Parent().SpanID())
+
+		attributes := map[string]string{}
+		for _, attribute := range insert.Attributes() {
+			attributes[string(attribute.Key)] = attribute.Value.Emit()
+		}
+		assert.Equal(t, "sqlite", attributes["db.system"])
+		assert.Contains(t, attributes["db.query.text"], "INSERT INTO")
+	}
+
+	// so is the count of a page
+	status = sendTraced(handler, http.MethodGet, "/api/users?page=1", "", "")
+	assert.Equal(t, 200, status)
+
+	var count sdktrace.ReadOnlySpan
+	for _, span := range spans.Ended() {
+		for _, attribute := range span.Attributes() {
+			if attribute.Key == "db.query.text" && strings.Contains(attribute.Value.Emit(), "COUNT(*)") {
+				count = span
+			}
+		}
+	}
+	assert.NotNil(t, count)
+
+	// errors are recorded on the service span
+	status = sendTraced(handler, http.MethodGet, "/api/users/7", "", "")
+	assert.Equal(t, 404, status)
+
+	for _, span := range spans.Ended() {
+		if span.Name() == "UserService.FindById" {
+			assert.Equal(t, "user not found", span.Status().Description)
+		}
+		if span.Name() == "GET /api/users/:userId" {
+			// a 404 isn't an error of the server
+			assert.Equal(t, "Unset", span.Status().Code.String())
+		}
+	}
+}
+
+func TestTracingStdout(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+
+	out := &bytes.Buffer{}
+	shutdown, err := app.StartTracing(context.Background(), config.TracingConfig{Exporter: "stdout", SampleRatio: 1, ServiceName: "latihan-restapi"}, out)
+	assert.Nil(t, err)
+
+	handler := setupTracingRouter(t)
+	assert.Equal(t, 200, sendTraced(handler, http.MethodGet, "/api/users", "", ""))
+
+	// flush the batch
+	assert.Nil(t, shutdown(context.Background()))
+	assert.Contains(t, out.String(), `"Name":"GET /api/users"`)
+	assert.Contains(t, out.String(), `"Name":"UserService.FindAll"`)
+	assert.Contains(t, out.String(), "latihan-restapi")
+}
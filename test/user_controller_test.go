@@ -15,10 +15,13 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/iqbaltaufiq/latihan-restapi/app"
 	"github.com/iqbaltaufiq/latihan-restapi/controller"
+	"github.com/iqbaltaufiq/latihan-restapi/exception"
 	"github.com/iqbaltaufiq/latihan-restapi/helper"
 	"github.com/iqbaltaufiq/latihan-restapi/middleware"
 	"github.com/iqbaltaufiq/latihan-restapi/model/domain"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
 	"github.com/iqbaltaufiq/latihan-restapi/repository"
 	"github.com/iqbaltaufiq/latihan-restapi/router"
 	"github.com/iqbaltaufiq/latihan-restapi/service"
@@ -32,7 +35,7 @@ import (
 // WARNING:
 // make sure to change the database to database for testing
 func setupDBTest() *sql.DB {
-	db, err := sql.Open("mysql", "root:@tcp(localhost:3306)/latihan_go_restapi_test")
+	db, err := sql.Open("mysql", "root:@tcp(localhost:3306)/latihan_go_restapi_test?parseTime=true")
 	helper.PanicIfError(err)
 
 	db.SetMaxOpenConns(20)
@@ -44,20 +47,24 @@ func setupDBTest() *sql.DB {
 }
 
 func setupRouter(db *sql.DB) http.Handler {
-	validate := validator.New()
-	userRepository := repository.NewUserRepository()
-	userService := service.NewUserService(userRepository, db, validate)
+	validate, err := app.NewValidator()
+	helper.PanicIfError(err)
+	userRepository := repository.NewUserRepository(db, repository.MySQL)
+	userService := service.NewUserService(userRepository, repository.NewSQLTransactor(db), validate, []byte("SECRET"))
 	userController := controller.NewUserController(userService)
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(db, repository.MySQL), repository.NewSQLTransactor(db), validate)
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
 
-	router := router.NewRouter(userController)
+	router := router.NewRouter(userController, apiKeyController, nil)
 
-	return middleware.NewAuthMiddleware(router)
+	return middleware.NewAuthMiddleware(router, apiKeyService, nil, "SECRET")
 }
 
-// truncate the table whenever you run a test
+// truncate the tables whenever you run a test
 // so we always run a test with an empty db
 func truncateDB(db *sql.DB) {
 	db.Exec("TRUNCATE user")
+	db.Exec("TRUNCATE api_keys")
 }
 
 func TestCreateUserSuccess(t *testing.T) {
@@ -125,21 +132,19 @@ func TestUpdateUserSuccess(t *testing.T) {
 
 	router := setupRouter(db)
 
-	// make a db transaction.
 	// insert an entry into db
 	// that will be updated afterwards.
-	tx, _ := db.Begin()
-	user := repository.NewUserRepository().Save(context.Background(), tx, domain.User{
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
 		Name:       "John Doe",
 		Occupation: "student",
 	})
-	tx.Commit()
 
 	fmt.Println(user)
 
 	// create a payload to be sent into request
-	payloadJSON, _ := json.Marshal(domain.User{
-		Name: "Jack",
+	payloadJSON, _ := json.Marshal(web.UserUpdatePayload{
+		Name:       "Jack",
+		Occupation: "teacher",
 	})
 
 	payload := strings.NewReader(string(payloadJSON))
@@ -161,6 +166,7 @@ func TestUpdateUserSuccess(t *testing.T) {
 	assert.Equal(t, 200, int(responseBody["code"].(float64)))
 	assert.Equal(t, "OK", responseBody["status"])
 	assert.Equal(t, user.Id, int(responseBody["data"].(map[string]interface{})["id"].(float64)))
+	assert.Equal(t, "teacher", responseBody["data"].(map[string]interface{})["occupation"])
 }
 
 func TestUpdateUserFailed(t *testing.T) {
@@ -171,20 +177,17 @@ func TestUpdateUserFailed(t *testing.T) {
 
 	router := setupRouter(db)
 
-	// make a db transaction.
 	// insert an entry into db
 	// that will be updated afterwards.
-	tx, _ := db.Begin()
-	user := repository.NewUserRepository().Save(context.Background(), tx, domain.User{
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
 		Name:       "John Doe",
 		Occupation: "student",
 	})
-	tx.Commit()
 
 	fmt.Println(user)
 
 	// create a payload to be sent into request
-	payloadJSON, _ := json.Marshal(domain.User{
+	payloadJSON, _ := json.Marshal(web.UserUpdatePayload{
 		Name: "",
 	})
 
@@ -206,6 +209,11 @@ func TestUpdateUserFailed(t *testing.T) {
 	assert.Equal(t, 400, response.StatusCode)
 	assert.Equal(t, 400, int(responseBody["code"].(float64)))
 	assert.Equal(t, "Bad Request", responseBody["status"])
+	// rejected by validation, not while decoding
+	assert.Equal(t, "invalid name, occupation", responseBody["data"])
+	fieldError := responseBody["errors"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "name", fieldError["field"])
+	assert.Equal(t, "required", fieldError["rule"])
 }
 
 func TestFindUserSuccess(t *testing.T) {
@@ -216,12 +224,10 @@ func TestFindUserSuccess(t *testing.T) {
 
 	router := setupRouter(db)
 
-	tx, _ := db.Begin()
-	user := repository.NewUserRepository().Save(context.Background(), tx, domain.User{
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
 		Name:       "John",
 		Occupation: "student",
 	})
-	tx.Commit()
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users/"+strconv.Itoa(user.Id), nil)
@@ -272,13 +278,11 @@ func TestDeleteUserSuccess(t *testing.T) {
 
 	router := setupRouter(db)
 
-	// make a db transaction
-	tx, _ := db.Begin()
-	user := repository.NewUserRepository().Save(context.Background(), tx, domain.User{
+	// insert a user to be deleted
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
 		Name:       "John",
 		Occupation: "student",
 	})
-	tx.Commit()
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest(http.MethodDelete, "http://localhost:3000/api/users/"+strconv.Itoa(user.Id), nil)
@@ -330,18 +334,15 @@ func TestFindUsers(t *testing.T) {
 
 	router := setupRouter(db)
 
-	// make a db transaction
-	// to insert few users
-	tx, _ := db.Begin()
-	user1 := repository.NewUserRepository().Save(context.Background(), tx, domain.User{
+	// insert few users
+	user1, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
 		Name:       "John",
 		Occupation: "student",
 	})
-	user2 := repository.NewUserRepository().Save(context.Background(), tx, domain.User{
+	user2, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
 		Name:       "Anne",
 		Occupation: "lecturer",
 	})
-	tx.Commit()
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users", nil)
@@ -384,3 +385,457 @@ func TestUnauthorized(t *testing.T) {
 	assert.Equal(t, 401, int(responseBody["code"].(float64)))
 	assert.Equal(t, "Unauthorized", responseBody["status"])
 }
+
+func TestFindUsersPaginated(t *testing.T) {
+	// make a connection to db
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	// insert few users to be paged through
+	for _, name := range []string{"Anne", "Bob", "Carl", "Dave", "Eve"} {
+		repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+			Name:       name,
+			Occupation: "student",
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users?page=2&size=2&sort=-name", nil)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("X-API-KEY", "SECRET")
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	data := responseBody["data"].([]interface{})
+	meta := responseBody["meta"].(map[string]interface{})
+
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, "Carl", data[0].(map[string]interface{})["name"])
+	assert.Equal(t, "Bob", data[1].(map[string]interface{})["name"])
+	assert.Equal(t, 2, int(meta["page"].(float64)))
+	assert.Equal(t, 2, int(meta["size"].(float64)))
+	assert.Equal(t, 5, int(meta["total_items"].(float64)))
+	assert.Equal(t, 3, int(meta["total_pages"].(float64)))
+}
+
+func TestFindUsersFiltered(t *testing.T) {
+	// make a connection to db
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+		Name:       "John",
+		Occupation: "student",
+	})
+	repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+		Name:       "Anne",
+		Occupation: "lecturer",
+	})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users?occupation=lecturer", nil)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("X-API-KEY", "SECRET")
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	data := responseBody["data"].([]interface{})
+
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 1, len(data))
+	assert.Equal(t, "Anne", data[0].(map[string]interface{})["name"])
+	assert.Equal(t, 1, int(responseBody["meta"].(map[string]interface{})["total_items"].(float64)))
+}
+
+func TestFindUsersInvalidQuery(t *testing.T) {
+	// make a connection to db
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	// sorting and filtering only work on known columns
+	for _, query := range []string{"?sort=password", "?password=123", "?size=1000", "?page=abc"} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users"+query, nil)
+		request.Header.Add("Content-Type", "application/json")
+		request.Header.Add("X-API-KEY", "SECRET")
+
+		router.ServeHTTP(recorder, request)
+
+		response := recorder.Result()
+		body, _ := io.ReadAll(response.Body)
+		var responseBody map[string]interface{}
+		json.Unmarshal(body, &responseBody)
+
+		assert.Equal(t, 400, response.StatusCode, query)
+		assert.Equal(t, "Bad Request", responseBody["status"], query)
+	}
+}
+
+func TestFindUsersWithCursor(t *testing.T) {
+	// make a connection to db
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	for _, name := range []string{"Eve", "Anne", "Dave", "Bob", "Carl"} {
+		repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+			Name:       name,
+			Occupation: "student",
+		})
+	}
+
+	// walk through all pages by following next_cursor
+	var names []interface{}
+	url := "http://localhost:3000/api/users?limit=2&sort=name"
+	for pages := 0; pages < 5; pages++ {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		request.Header.Add("Content-Type", "application/json")
+		request.Header.Add("X-API-KEY", "SECRET")
+
+		router.ServeHTTP(recorder, request)
+
+		response := recorder.Result()
+		body, _ := io.ReadAll(response.Body)
+		var responseBody map[string]interface{}
+		json.Unmarshal(body, &responseBody)
+
+		assert.Equal(t, 200, response.StatusCode)
+		for _, user := range responseBody["data"].([]interface{}) {
+			names = append(names, user.(map[string]interface{})["name"])
+		}
+
+		nextCursor := responseBody["meta"].(map[string]interface{})["next_cursor"]
+		if nextCursor == nil {
+			break
+		}
+		url = "http://localhost:3000/api/users?limit=2&after=" + nextCursor.(string)
+	}
+
+	assert.Equal(t, []interface{}{"Anne", "Bob", "Carl", "Dave", "Eve"}, names)
+}
+
+func TestFindUsersWithTamperedCursor(t *testing.T) {
+	// make a connection to db
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users?after=eyJzIjpbImlkIl0sInYiOlsiMCJdfQ.c2lnbmF0dXJl", nil)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("X-API-KEY", "SECRET")
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestFindUserInvalidId(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:3000/api/users/abc", nil)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("X-API-KEY", "SECRET")
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Equal(t, "Bad Request", responseBody["status"])
+	assert.Equal(t, "userId must be a number", responseBody["data"])
+}
+
+func TestPatchUser(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+		Name:       "John",
+		Occupation: "student",
+	})
+
+	// every patch is applied on top of the previous one
+	patches := []struct {
+		contentType string
+		patch       string
+		code        int
+		name        string
+		occupation  string
+		version     int
+	}{
+		{"application/merge-patch+json", `{"occupation": "teacher"}`, 200, "John", "teacher", 2},
+		{"application/json-patch+json", `[{"op": "replace", "path": "/name", "value": "Jack"}]`, 200, "Jack", "teacher", 3},
+		{"application/json-patch+json", `[{"op": "test", "path": "/name", "value": "John"}]`, 409, "Jack", "teacher", 3},
+		{"application/json-patch+json", `[{"op": "replace", "path": "/id", "value": 100}]`, 400, "Jack", "teacher", 3},
+		{"application/merge-patch+json", `{"name": null}`, 400, "Jack", "teacher", 3},
+		{"application/json", `{"name": "Anne"}`, 415, "Jack", "teacher", 3},
+		// fields users don't have are refused
+		{"application/merge-patch+json", `{"age": 20}`, 400, "Jack", "teacher", 3},
+		{"application/json-patch+json", `[{"op": "add", "path": "/age", "value": 20}]`, 400, "Jack", "teacher", 3},
+		// nothing changed, nothing is written
+		{"application/merge-patch+json", `{"name": "Jack"}`, 200, "Jack", "teacher", 3},
+	}
+
+	for _, patch := range patches {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPatch, "http://localhost:3000/api/users/"+strconv.Itoa(user.Id), strings.NewReader(patch.patch))
+		request.Header.Add("Content-Type", patch.contentType)
+		request.Header.Add("X-API-KEY", "SECRET")
+
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, patch.code, recorder.Result().StatusCode, patch.patch)
+
+		userInDB, _ := repository.NewUserRepository(db, repository.MySQL).FindById(context.Background(), user.Id, "", false)
+
+		assert.Equal(t, patch.name, userInDB.Name, patch.patch)
+		assert.Equal(t, patch.occupation, userInDB.Occupation, patch.patch)
+		assert.Equal(t, patch.version, userInDB.Version, patch.patch)
+	}
+}
+
+func TestUpdateUserWithETag(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+		Name:       "John",
+		Occupation: "student",
+	})
+
+	send := func(method string, body string, header string, value string) *http.Response {
+		response, _ := sendRequest(router, method, "/api/users/"+strconv.Itoa(user.Id), body, "X-API-KEY", "SECRET", header, value)
+		return response
+	}
+
+	// read the user and remember its etag
+	response := send(http.MethodGet, "", "", "")
+	etag := response.Header.Get("ETag")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, `"1"`, etag)
+
+	// nothing changed, so nothing is sent
+	response = send(http.MethodGet, "", "If-None-Match", etag)
+	assert.Equal(t, 304, response.StatusCode)
+
+	// first update wins and bumps the version
+	response = send(http.MethodPut, `{"name": "Jack", "occupation": "student"}`, "If-Match", etag)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, `"2"`, response.Header.Get("ETag"))
+
+	// second update with the stale etag is rejected
+	response = send(http.MethodPut, `{"name": "Anne", "occupation": "student"}`, "If-Match", etag)
+	assert.Equal(t, 412, response.StatusCode)
+
+	response = send(http.MethodDelete, "", "If-Match", etag)
+	assert.Equal(t, 412, response.StatusCode)
+
+	response = send(http.MethodGet, "", "If-None-Match", etag)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// any tag of a list may match, weak tags never do
+	response = send(http.MethodPut, `{"name": "Anne", "occupation": "student"}`, "If-Match", `"1", "2"`)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, `"3"`, response.Header.Get("ETag"))
+
+	response = send(http.MethodDelete, "", "If-Match", `W/"3"`)
+	assert.Equal(t, 412, response.StatusCode)
+
+	response = send(http.MethodDelete, "", "If-Match", `"2", W/"3", "3"`)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestSoftDeleteAndRestoreUser(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	user, _ := repository.NewUserRepository(db, repository.MySQL).Save(context.Background(), domain.User{
+		Name:       "John",
+		Occupation: "student",
+	})
+
+	send := func(method string, url string) (*http.Response, map[string]interface{}) {
+		return sendRequest(router, method, url, "", "X-API-KEY", "SECRET")
+	}
+
+	userUrl := "/api/users/" + strconv.Itoa(user.Id)
+
+	response, _ := send(http.MethodDelete, userUrl)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// deleted users are hidden by default
+	response, _ = send(http.MethodGet, userUrl)
+	assert.Equal(t, 404, response.StatusCode)
+
+	_, responseBody := send(http.MethodGet, "/api/users")
+	assert.Equal(t, 0, len(responseBody["data"].([]interface{})))
+
+	// but still there when asked
+	response, responseBody = send(http.MethodGet, userUrl+"?include_deleted=true")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotNil(t, responseBody["data"].(map[string]interface{})["deleted_at"])
+
+	_, responseBody = send(http.MethodGet, "/api/users?include_deleted=true")
+	assert.Equal(t, 1, len(responseBody["data"].([]interface{})))
+
+	// restoring brings it back
+	response, responseBody = send(http.MethodPost, userUrl+"/restore")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Nil(t, responseBody["data"].(map[string]interface{})["deleted_at"])
+
+	response, _ = send(http.MethodGet, userUrl)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// a user that isn't deleted can't be restored
+	response, _ = send(http.MethodPost, userUrl+"/restore")
+	assert.Equal(t, 409, response.StatusCode)
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	userService := service.NewUserService(repository.NewUserRepository(db, repository.MySQL), repository.NewSQLTransactor(db), validator.New(), []byte("SECRET"))
+
+	john, _ := userService.Create(context.Background(), web.UserCreatePayload{Name: "John", Occupation: "student"})
+	anne, _ := userService.Create(context.Background(), web.UserCreatePayload{Name: "Anne", Occupation: "lecturer"})
+	userService.Delete(context.Background(), john.Id, nil)
+
+	// john was deleted less than an hour ago
+	purged, err := userService.Purge(context.Background(), time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, purged)
+
+	// deleted_at is stored in whole seconds,
+	// look a bit into the future to catch john
+	purged, err = userService.Purge(context.Background(), -time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = userService.FindById(context.Background(), john.Id, true)
+	assert.ErrorIs(t, err, exception.ErrNotFound)
+
+	_, err = userService.FindById(context.Background(), anne.Id, false)
+	assert.Nil(t, err)
+}
+
+func TestBulkUsers(t *testing.T) {
+	// make a database connection
+	// make sure to use database for testing purposes only
+	db := setupDBTest()
+	truncateDB(db)
+
+	router := setupRouter(db)
+
+	send := func(method string, url string, payload string) (*http.Response, map[string]interface{}) {
+		return sendRequest(router, method, url, payload, "X-API-KEY", "SECRET")
+	}
+
+	statuses := func(responseBody map[string]interface{}) []int {
+		var statuses []int
+		for _, item := range responseBody["data"].([]interface{}) {
+			statuses = append(statuses, int(item.(map[string]interface{})["status"].(float64)))
+		}
+		return statuses
+	}
+
+	countUsers := func() int {
+		var total int
+		db.QueryRow("SELECT COUNT(*) FROM user WHERE deleted_at IS NULL").Scan(&total)
+		return total
+	}
+
+	users := `[{"name": "John", "occupation": "student"}, {"name": "", "occupation": "student"}, {"name": "Anne", "occupation": "lecturer"}]`
+
+	// one invalid user fails the whole request
+	response, responseBody := send(http.MethodPost, "/api/bulk/users", users)
+	assert.Equal(t, 422, response.StatusCode)
+	assert.Equal(t, []int{424, 400, 424}, statuses(responseBody))
+	assert.Equal(t, 0, countUsers())
+
+	// unless the valid ones are allowed through
+	response, responseBody = send(http.MethodPost, "/api/bulk/users?mode=best_effort", users)
+	assert.Equal(t, 207, response.StatusCode)
+	assert.Equal(t, []int{200, 400, 200}, statuses(responseBody))
+	assert.Equal(t, 2, countUsers())
+
+	items := responseBody["data"].([]interface{})
+	johnId := int(items[0].(map[string]interface{})["data"].(map[string]interface{})["id"].(float64))
+	anneId := int(items[2].(map[string]interface{})["data"].(map[string]interface{})["id"].(float64))
+
+	// every id is the one of its own user
+	var name string
+	assert.Nil(t, db.QueryRow("SELECT name FROM user WHERE id = ?", anneId).Scan(&name))
+	assert.Equal(t, "Anne", name)
+
+	response, responseBody = send(http.MethodPut, "/api/bulk/users?mode=best_effort", fmt.Sprintf(`[{"id": %d, "name": "Jack", "occupation": "teacher"}, {"id": 1000, "name": "Nobody", "occupation": "none"}]`, johnId))
+	assert.Equal(t, 207, response.StatusCode)
+	assert.Equal(t, []int{200, 404}, statuses(responseBody))
+
+	response, responseBody = send(http.MethodPut, "/api/bulk/users", fmt.Sprintf(`[{"id": %d, "name": "Jack", "occupation": "teacher", "version": 2}, {"id": %d, "name": "Anne", "occupation": "teacher", "version": 5}]`, johnId, anneId))
+	assert.Equal(t, 422, response.StatusCode)
+	assert.Equal(t, []int{424, 412}, statuses(responseBody))
+
+	response, responseBody = send(http.MethodDelete, "/api/bulk/users", fmt.Sprintf(`[%d, %d, 1000]`, johnId, anneId))
+	assert.Equal(t, 422, response.StatusCode)
+	assert.Equal(t, []int{424, 424, 404}, statuses(responseBody))
+	assert.Equal(t, 2, countUsers())
+
+	response, _ = send(http.MethodDelete, "/api/bulk/users", fmt.Sprintf(`[%d, %d]`, johnId, anneId))
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 0, countUsers())
+
+	response, _ = send(http.MethodPost, "/api/bulk/users", `{"name": "John"}`)
+	assert.Equal(t, 400, response.StatusCode)
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iqbaltaufiq/latihan-restapi/helper"
+	"github.com/iqbaltaufiq/latihan-restapi/model/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// This is an integration testing for the invalid fields
+// listed in validation errors, in the language of the client.
+
+func TestValidationErrors(t *testing.T) {
+	router := setupStorageRouter(t, "memory")
+
+	send := func(method string, url string, payload string, headers ...string) (int, map[string]json.RawMessage) {
+		response, _ := sendRequest(router, method, url, payload, append([]string{"X-API-KEY", "SECRET"}, headers...)...)
+
+		var responseBody map[string]json.RawMessage
+		json.NewDecoder(response.Body).Decode(&responseBody)
+		return response.StatusCode, responseBody
+	}
+
+	fieldErrors := func(raw json.RawMessage) []web.FieldError {
+		var fields []web.FieldError
+		assert.Nil(t, json.Unmarshal(raw, &fields))
+		return fields
+	}
+
+	invalidUser := `{"name": "", "occupation": "` + strings.Repeat("a", 201) + `"}`
+
+	status, body := send(http.MethodPost, "/api/users", invalidUser)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, `"invalid name, occupation"`, string(body["data"]))
+	assert.Equal(t, []web.FieldError{
+		{Field: "name", JSONPath: "$.name", Rule: "required", Message: "name is a required field"},
+		{Field: "occupation", JSONPath: "$.occupation", Rule: "max", Param: "200", Message: "occupation must be a maximum of 200 characters in length"},
+	}, fieldErrors(body["errors"]))
+
+	// indonesian, the best match of Accept-Language
+	status, body = send(http.MethodPost, "/api/users", invalidUser, "Accept-Language", "fr;q=0.9, id-ID, en;q=0.8")
+	assert.Equal(t, 400, status)
+	fields := fieldErrors(body["errors"])
+	if assert.Len(t, fields, 2) {
+		assert.Equal(t, "name wajib diisi", fields[0].Message)
+		assert.Equal(t, "name", fields[0].Field)
+	}
+
+	// unknown languages get english
+	status, body = send(http.MethodPost, "/api/users", invalidUser, "Accept-Language", "de")
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "name is a required field", fieldErrors(body["errors"])[0].Message)
+
+	// an extension member of problem+json
+	status, body = send(http.MethodPut, "/api/users/1", `{"id": 1, "name": "John", "occupation": "student", "version": -1}`, "Accept", "application/problem+json")
+	assert.Equal(t, 400, status)
+	assert.Equal(t, []web.FieldError{
+		{Field: "version", JSONPath: "$.version", Rule: "min", Param: "0", Message: "version must be 0 or greater"},
+	}, fieldErrors(body["errors"]))
+
+	// every item of a bulk request has its own
+	status, body = send(http.MethodPost, "/api/bulk/users?mode=best_effort", `[{"name": "John", "occupation": "student"}, {"name": "Anne"}]`)
+	assert.Equal(t, 207, status)
+	var items []web.BulkItemResponse
+	assert.Nil(t, json.Unmarshal(body["data"], &items))
+	if assert.Len(t, items, 2) {
+		assert.Nil(t, items[0].Errors)
+		assert.Equal(t, "occupation", items[1].Errors[0].Field)
+		assert.Equal(t, "$[1].occupation", items[1].Errors[0].JSONPath)
+		assert.Equal(t, "required", items[1].Errors[0].Rule)
+	}
+
+	// the body of a bulk request is the array itself
+	status, body = send(http.MethodPost, "/api/bulk/users", `[]`)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, []web.FieldError{
+		{Field: "users", JSONPath: "$", Rule: "min", Param: "1", Message: "users must contain at least 1 item"},
+	}, fieldErrors(body["errors"]))
+
+	// query params are named as in the query string, they have no json path
+	queries := map[string]web.FieldError{
+		"/api/users?size=1000":   {Field: "size", Rule: "max", Param: "100"},
+		"/api/users?sort=bogus":  {Field: "sort", Rule: "oneof", Param: "id -id name -name occupation -occupation owner -owner"},
+		"/api/users?password=1":  {Field: "password", Rule: "oneof", Param: "name occupation owner"},
+		"/api/bulk/users?mode=x": {Field: "mode", Rule: "oneof", Param: "atomic best_effort"},
+	}
+	for url, expected := range queries {
+		method, payload := http.MethodGet, ""
+		if strings.HasPrefix(url, "/api/bulk") {
+			method, payload = http.MethodPost, `[{"name": "John", "occupation": "student"}]`
+		}
+
+		status, body = send(method, url, payload)
+		assert.Equal(t, 400, status, url)
+		fields := fieldErrors(body["errors"])
+		if assert.Len(t, fields, 1, url) {
+			assert.Equal(t, expected.Field, fields[0].Field, url)
+			assert.Equal(t, "", fields[0].JSONPath, url)
+			assert.Equal(t, expected.Rule, fields[0].Rule, url)
+			assert.Equal(t, expected.Param, fields[0].Param, url)
+			assert.True(t, strings.HasPrefix(fields[0].Message, expected.Field+" "), fields[0].Message)
+		}
+	}
+	assert.NotContains(t, string(body["errors"]), "json_path")
+
+	// errors that aren't about fields have none
+	status, body = send(http.MethodGet, "/api/users/abc", "")
+	assert.Equal(t, 400, status)
+	assert.Nil(t, body["errors"])
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	assert.Equal(t, []string{"id_id", "id", "en"}, helper.AcceptLanguages("en;q=0.5, id-ID"))
+	assert.Equal(t, []string{"en"}, helper.AcceptLanguages("*, fr;q=0, en"))
+	assert.Nil(t, helper.AcceptLanguages(""))
+}